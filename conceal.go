@@ -0,0 +1,219 @@
+package flac
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/pchchv/flac/frame"
+)
+
+// ConcealMode specifies how ParseNext conceals corrupt audio frames.
+type ConcealMode uint8
+
+// Error concealment modes.
+const (
+	// ConcealSilence replaces corrupt frames with silence.
+	ConcealSilence ConcealMode = iota
+	// ConcealFade replaces corrupt frames with the audio samples of the last
+	// frame decoded, faded out linearly over the block size. Consecutive
+	// corrupt frames after the first are replaced with silence.
+	ConcealFade
+)
+
+// Concealment describes an audio frame replaced by ParseNext
+// in error-concealing mode.
+type Concealment struct {
+	// Error of the corrupt frame.
+	Err error
+	// First sample number of the replaced frame.
+	SampleNum uint64
+	// Block size of the replaced frame.
+	BlockSize uint16
+	// Concealment mode of the replaced frame;
+	// ConcealSilence if there was no frame to fade out.
+	Mode ConcealMode
+}
+
+// concealer holds the state of the error-concealing mode of a stream.
+type concealer struct {
+	// Concealment mode of corrupt frames.
+	mode ConcealMode
+	// Reports concealment events; may be nil.
+	fn func(Concealment)
+	// Last frame decoded, to be faded out; nil if concealed.
+	last *frame.Frame
+	// Sample number following the last frame returned.
+	next uint64
+	// Frame following a gap in the sample numbers of frames,
+	// returned once the gap has been concealed; or nil.
+	pending *frame.Frame
+	// Error of the pending frame, if corrupt.
+	pendingErr error
+}
+
+// EnableConcealment enables the error-concealing mode of ParseNext.
+// Frames which fail subframe decoding or the CRC-16 checksum are replaced with
+// silence, or the last frame decoded faded out, for the block size declared
+// by the frame header; decoding continues with the next frame. Samples missing
+// from the stream, such as frames skipped in resync mode and audio truncated
+// before the number of samples of StreamInfo, are replaced with silence.
+// The frames returned by ParseNext thereby cover every sample of the stream.
+//
+// Every concealed frame is reported to fn (if not nil). Frames with corrupt
+// headers cannot be concealed by their block size; use EnableResync to
+// skip them.
+func (stream *Stream) EnableConcealment(mode ConcealMode, fn func(Concealment)) {
+	stream.conceal = &concealer{mode: mode, fn: fn}
+}
+
+// parseNextConceal parses the entire next frame including audio samples,
+// concealing corrupt frames as described by EnableConcealment.
+func (stream *Stream) parseNextConceal() (*frame.Frame, error) {
+	c := stream.conceal
+	if c.pending == nil {
+		var f *frame.Frame
+		var err error
+		if stream.resync {
+			f, err = stream.parseNextResync(new(frame.Frame))
+		} else {
+			f, err = stream.parseNextRealign()
+		}
+
+		switch {
+		case err == io.EOF:
+			if nsamples := stream.Info.NSamples; c.next < nsamples {
+				return stream.concealGap(nsamples), nil
+			}
+			return nil, io.EOF
+		case err != nil && f == nil:
+			return nil, err
+		}
+		c.pending, c.pendingErr = f, err
+	}
+
+	f, err := c.pending, c.pendingErr
+	if first := stream.sampleNumber(f); first > c.next {
+		return stream.concealGap(first), nil
+	}
+	c.pending, c.pendingErr = nil, nil
+
+	if err != nil {
+		return stream.concealFrame(f.Header, err), nil
+	}
+
+	c.last = f
+	c.next = stream.sampleNumber(f) + uint64(f.BlockSize)
+	return f, nil
+}
+
+// parseNextRealign parses the entire next frame including audio samples.
+// If the subframes of the frame are corrupt, the frame is returned along with
+// the error, and the stream is realigned to the header of the next frame.
+// It returns a nil frame if the frame header is corrupt.
+func (stream *Stream) parseNextRealign() (*frame.Frame, error) {
+	f, err := frame.New(stream.r)
+	if err != nil {
+		return nil, err
+	}
+	stream.setNextSample(f)
+
+	if err := f.Parse(); err != nil {
+		// the next frame starts at or after the end of the corrupt frame
+		if err := stream.realign(stream.sampleNumber(f) + uint64(f.BlockSize)); err != nil && err != io.EOF {
+			return nil, err
+		}
+		return f, err
+	}
+
+	return f, nil
+}
+
+// realign skips to the next frame header consistent with StreamInfo,
+// which starts at or after the given sample number.
+func (stream *Stream) realign(sampleNum uint64) error {
+	p := stream.r.(peeker)
+	for {
+		if _, err := syncPeeker(p); err != nil {
+			return err
+		}
+
+		if f, err := stream.peekFrameHeader(p); err == nil && stream.sampleNumber(f) >= sampleNum {
+			return nil
+		}
+
+		if _, err := p.Discard(1); err != nil {
+			return err
+		}
+	}
+}
+
+// concealGap returns a frame of silence concealing the samples missing
+// before the given sample number, up to the largest block size of the stream.
+func (stream *Stream) concealGap(sampleNum uint64) *frame.Frame {
+	c := stream.conceal
+	info := stream.Info
+	blockSize := uint64(info.BlockSizeMax)
+	if blockSize == 0 {
+		blockSize = 4096
+	}
+
+	if n := sampleNum - c.next; n < blockSize {
+		blockSize = n
+	}
+
+	hdr := frame.Header{
+		BlockSize:     uint16(blockSize),
+		SampleRate:    info.SampleRate,
+		Channels:      frame.Channels(info.NChannels - 1),
+		BitsPerSample: info.BitsPerSample,
+		Num:           c.next,
+	}
+
+	// gaps of fixed block size streams are numbered by frame, unless they
+	// start within a frame, such as after a short final frame
+	fixed := info.BlockSizeMax != 0 && info.BlockSizeMin == info.BlockSizeMax
+	if fixed && c.next%uint64(info.BlockSizeMax) == 0 {
+		hdr.HasFixedBlockSize = true
+		hdr.Num = c.next / uint64(info.BlockSizeMax)
+	}
+
+	err := fmt.Errorf("flac.Stream.ParseNext: %d samples missing before sample number %d", sampleNum-c.next, sampleNum)
+	c.last = nil
+	return stream.concealFrame(hdr, err)
+}
+
+// concealFrame returns a frame replacing the audio samples of the frame with
+// the given header, and reports the concealment.
+func (stream *Stream) concealFrame(hdr frame.Header, err error) *frame.Frame {
+	c := stream.conceal
+	mode := c.mode
+	if c.last == nil {
+		mode = ConcealSilence
+	}
+
+	n := int(hdr.BlockSize)
+	f := &frame.Frame{Header: hdr, Subframes: make([]*frame.Subframe, hdr.Channels.Count())}
+	for channel := range f.Subframes {
+		samples := make([]int32, n)
+		if mode == ConcealFade && channel < len(c.last.Subframes) {
+			// the last frame is repeated if shorter than the block size
+			last := c.last.Subframes[channel].Samples[:c.last.BlockSize]
+			for i := range samples {
+				samples[i] = int32(int64(last[i%len(last)]) * int64(n-i) / int64(n))
+			}
+		}
+		f.Subframes[channel] = &frame.Subframe{
+			SubHeader: frame.SubHeader{Pred: frame.PredVerbatim},
+			Samples:   samples,
+			NSamples:  n,
+		}
+	}
+
+	if c.fn != nil {
+		c.fn(Concealment{Err: err, SampleNum: stream.sampleNumber(f), BlockSize: hdr.BlockSize, Mode: mode})
+	}
+
+	c.last = nil
+	c.next = stream.sampleNumber(f) + uint64(hdr.BlockSize)
+	return f
+}
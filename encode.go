@@ -2,8 +2,10 @@ package flac
 
 import (
 	"crypto/md5"
+	"fmt"
 	"hash"
 	"io"
+	"sync"
 
 	"github.com/icza/bitio"
 	"github.com/pchchv/flac/meta"
@@ -15,23 +17,69 @@ type Encoder struct {
 	*Stream
 	// Underlying io.Writer or io.WriteCloser to the output stream.
 	w io.Writer
-	// Minimum and maximum block size (in samples) of frames written by encoder.
+	// Minimum and maximum block size (in samples) of frames written by encoder,
+	// where the minimum excludes the most recent frame.
 	blockSizeMin, blockSizeMax uint16
+	// Block size (in samples) of the most recent frame written by encoder.
+	lastBlockSize uint16
 	// Minimum and maximum frame size (in bytes) of frames written by encoder.
 	frameSizeMin, frameSizeMax uint32
+	// Frame sizes of the stream are unknown, as they were not stated
+	// in StreamInfo of the existing stream appended to.
+	frameSizeUnknown bool
 	// MD5 running hash of unencoded audio samples.
 	md5sum hash.Hash
 	// Total number of samples (per channel) written by encoder.
 	nsamples uint64
+	// Total number of frames written by encoder.
+	nframes uint64
 	// Current frame number if block size is fixed,
 	// and the first sample number of the current frame otherwise.
 	curNum uint64
+	// Specifies if the encoder analyzes the audio samples of each subframe
+	// to determine its prediction method, instead of using the
+	// subframe header provided by the caller.
+	analysis bool
+	// Options used to encode frames; nil if the encoder has no options.
+	opts *EncoderOptions
+	// Apodization windows of each block size analyzed, protected by windowsMu.
+	windows   map[int][][]float64
+	windowsMu sync.Mutex
+	// Frames encoded concurrently by workers, in stream order,
+	// which are yet to be written to the output stream.
+	queue []*frameJob
+	// Frames to be encoded by workers; nil until the workers are started.
+	jobs chan *frameJob
+	// Workers which are yet to exit.
+	workers sync.WaitGroup
+	// Number of bytes and samples (per channel) of frames written to the output stream.
+	frameOffset, frameSample uint64
+	// Target sample numbers of reserved seek points which are yet to be filled in,
+	// and the number of seek points filled in.
+	seekTargets []uint64
+	nseekPoints int
+	// Audio samples of each channel buffered by WriteSamples and
+	// WriteSamplesPlanar, which are yet to be encoded.
+	pending [][]int32
+	// First error encountered while encoding or writing a frame, after which
+	// the output stream is incomplete; returned by all subsequent writes.
+	err error
 }
 
 // NewEncoder returns a new FLAC encoder for the
 // given metadata StreamInfo block and optional metadata blocks.
 func NewEncoder(w io.Writer, info *meta.StreamInfo, blocks ...*meta.Block) (*Encoder, error) {
-	// store FLAC signature
+	return NewEncoderOptions(w, info, nil, blocks...)
+}
+
+// NewEncoderOptions returns a new FLAC encoder for the given metadata
+// StreamInfo block, encoder options and optional metadata blocks.
+//
+// If opts is nil, it is equivalent to NewEncoder.
+// Otherwise, prediction analysis is enabled using the given options
+// (see EnablePredictionAnalysis), and the minimum and maximum block size
+// of StreamInfo are derived from the options when these are unset.
+func NewEncoderOptions(w io.Writer, info *meta.StreamInfo, opts *EncoderOptions, blocks ...*meta.Block) (*Encoder, error) {
 	enc := &Encoder{
 		Stream: &Stream{
 			Info:   info,
@@ -41,6 +89,27 @@ func NewEncoder(w io.Writer, info *meta.StreamInfo, blocks ...*meta.Block) (*Enc
 		md5sum: md5.New(),
 	}
 
+	if opts != nil {
+		o, err := newEncoderOptions(info, opts)
+		if err != nil {
+			return nil, err
+		}
+
+		enc.opts, enc.analysis = o, true
+		if info.BlockSizeMin == 0 && info.BlockSizeMax == 0 {
+			info.BlockSizeMin = o.minBlockSize()
+			info.BlockSizeMax = o.BlockSize
+		}
+
+		if interval := o.seekInterval(info.SampleRate); interval > 0 {
+			if blocks, err = enc.reserveSeekTable(interval, blocks); err != nil {
+				return nil, err
+			}
+			enc.Blocks = blocks
+		}
+	}
+
+	// store FLAC signature
 	bw := bitio.NewWriter(w)
 	if _, err := bw.Write(flacSignature); err != nil {
 		return nil, err
@@ -66,24 +135,100 @@ func NewEncoder(w io.Writer, info *meta.StreamInfo, blocks ...*meta.Block) (*Enc
 	return enc, nil
 }
 
-// Close closes the underlying io.Writer of the encoder and flushes any pending writes.
+// newEncoderOptions validates the given encoder options for a stream with the
+// given StreamInfo metadata block, and returns a copy with defaults filled in.
+func newEncoderOptions(info *meta.StreamInfo, opts *EncoderOptions) (*EncoderOptions, error) {
+	if err := opts.validate(); err != nil {
+		return nil, err
+	}
+
+	// copy options to prevent modification after validation
+	o := opts.clone()
+	if len(o.Apodizations) == 0 {
+		o.Apodizations = []Apodization{Tukey(0.5)}
+	}
+
+	// a seek interval shorter than one sample would silently disable the seek table
+	if o.SeekIntervalSamples == 0 && o.SeekInterval > 0 && o.seekInterval(info.SampleRate) == 0 {
+		return nil, fmt.Errorf("invalid seek interval %v; shorter than one sample at sample rate %d Hz", o.SeekInterval, info.SampleRate)
+	}
+
+	if o.Subset {
+		if err := checkSubset(info, &o); err != nil {
+			return nil, err
+		}
+	}
+
+	return &o, nil
+}
+
+// EnablePredictionAnalysis specifies whether the encoder should analyze
+// the audio samples of each frame written by WriteFrame.
+// When enabled, the prediction method, prediction order and
+// residual coding parameters of each subframe are determined by the encoder,
+// and the subframe headers provided by the caller are ignored.
+// Frames may then carry only audio samples;
+// the remaining frame header fields are derived from StreamInfo.
+//
+// The analysis uses the encoder options given to NewEncoderOptions,
+// or the options of DefaultLevel if the encoder has no options.
+func (enc *Encoder) EnablePredictionAnalysis(enable bool) {
+	if enable && enc.opts == nil {
+		opts := LevelOptions(DefaultLevel)
+		if enc.Info.BlockSizeMax >= 16 {
+			opts.BlockSize = enc.Info.BlockSizeMax
+		}
+		enc.opts = opts
+	}
+	enc.analysis = enable
+}
+
+// Close closes the underlying io.Writer of the encoder and flushes any pending writes,
+// including audio samples buffered by WriteSamples and WriteSamplesPlanar.
 // If the io.Writer implements io.Seeker,
 // the encoder will update the StreamInfo metadata block with the
 // MD5 checksum of the unencoded audio samples,
 // the number of samples,
-// and the minimum and maximum frame size and block size.
+// the minimum and maximum frame size and block size,
+// and the seek points of the SeekTable reserved by the encoder.
+//
+// Close must be called even if writing audio frames failed,
+// to stop the workers of the encoder. If a frame could not be encoded or
+// written, the StreamInfo metadata block is not updated and the error is returned.
 func (enc *Encoder) Close() error {
+	if enc.err != nil {
+		return enc.fail(enc.err)
+	}
+
+	// encode final partial block of buffered audio samples,
+	// and write frames encoded by workers
+	if err := enc.flushPending(); err != nil {
+		enc.stopWorkers()
+		return err
+	}
+
+	if err := enc.flushQueue(); err != nil {
+		return err
+	}
+
 	// update StreamInfo metadata block
 	if ws, ok := enc.w.(io.WriteSeeker); ok {
 		if _, err := ws.Seek(int64(len(flacSignature)), io.SeekStart); err != nil {
 			return err
 		}
-		// update minimum and maximum block size (in samples) of FLAC stream
+		// update minimum and maximum block size (in samples) of FLAC stream;
+		// the last block only counts towards the minimum if it is the only block
+		if enc.blockSizeMin == 0 {
+			enc.blockSizeMin = enc.lastBlockSize
+		}
 		enc.Info.BlockSizeMin = enc.blockSizeMin
 		enc.Info.BlockSizeMax = enc.blockSizeMax
 		// update minimum and maximum frame size (in bytes) of FLAC stream
 		enc.Info.FrameSizeMin = enc.frameSizeMin
 		enc.Info.FrameSizeMax = enc.frameSizeMax
+		if enc.frameSizeUnknown {
+			enc.Info.FrameSizeMin, enc.Info.FrameSizeMax = 0, 0
+		}
 		// update total number of samples (per channel) of FLAC stream
 		enc.Info.NSamples = enc.nsamples
 		// update MD5 checksum of the unencoded audio samples
@@ -98,6 +243,14 @@ func (enc *Encoder) Close() error {
 			return err
 		}
 
+		// write seek points of reserved SeekTable metadata block,
+		// which directly follows StreamInfo
+		if enc.seekTable != nil {
+			if err := encodeSeekTable(bw, enc.seekTable, len(enc.Blocks) == 1); err != nil {
+				return err
+			}
+		}
+
 		if _, err := bw.Align(); err != nil {
 			return err
 		}
@@ -109,3 +262,15 @@ func (enc *Encoder) Close() error {
 
 	return nil
 }
+
+// fail records the given error of a frame which could not be encoded or
+// written, unless an earlier error was recorded, and stops the workers of
+// the encoder. It returns the first error recorded.
+func (enc *Encoder) fail(err error) error {
+	if enc.err == nil {
+		enc.err = err
+	}
+
+	enc.stopWorkers()
+	return enc.err
+}
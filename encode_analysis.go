@@ -0,0 +1,245 @@
+package flac
+
+import (
+	mathbits "math/bits"
+
+	"github.com/pchchv/flac/frame"
+	"github.com/pchchv/flac/internal/bits"
+)
+
+const (
+	// maxFixedOrder is the highest prediction order of fixed linear prediction.
+	maxFixedOrder = 4
+)
+
+// fillFrameHeader completes the header of a frame which only carries audio samples,
+// using the properties of the StreamInfo metadata block of the encoder.
+func (enc *Encoder) fillFrameHeader(f *frame.Frame) {
+	for _, subframe := range f.Subframes {
+		if subframe.NSamples == 0 {
+			subframe.NSamples = len(subframe.Samples)
+		}
+	}
+
+	if f.BlockSize == 0 && len(f.Subframes) > 0 {
+		f.BlockSize = uint16(f.Subframes[0].NSamples)
+	}
+
+	if f.BitsPerSample == 0 {
+		f.BitsPerSample = enc.Info.BitsPerSample
+	}
+
+	// subset streams store the sample rate in each frame header
+	if f.SampleRate == 0 && enc.opts.Subset {
+		f.SampleRate = enc.Info.SampleRate
+	}
+
+	// default to independent channels if the channel assignment of
+	// the frame does not match the channel count of the stream
+	if nchannels := int(enc.Info.NChannels); f.Channels.Count() != nchannels {
+		f.Channels = frame.Channels(nchannels - 1)
+	}
+}
+
+// analyzeFrame determines the channel assignment of the frame and the
+// prediction method and residual coding parameters of each subframe,
+// and stores them in the frame header and subframe headers.
+// It returns the size in bits of the encoded subframes.
+func (enc *Encoder) analyzeFrame(f *frame.Frame) (uint64, error) {
+	if f.Channels.Count() == 2 {
+		return enc.analyzeStereo(f)
+	}
+
+	var total uint64
+	for _, subframe := range f.Subframes {
+		hdr, nbits, err := enc.analyze(subframe.Samples, uint(f.BitsPerSample))
+		if err != nil {
+			return 0, err
+		}
+		subframe.SubHeader = hdr
+		total += nbits
+	}
+
+	return total, nil
+}
+
+// shareFrame returns a copy of the headers of the given audio frame,
+// which shares the audio samples of its subframes.
+func shareFrame(f *frame.Frame) *frame.Frame {
+	shared := &frame.Frame{Header: f.Header}
+	for _, subframe := range f.Subframes {
+		s := *subframe
+		shared.Subframes = append(shared.Subframes, &s)
+	}
+
+	return shared
+}
+
+// analyze determines the prediction method and residual coding parameters
+// which encode the given audio samples using the fewest bits.
+// It returns the subframe header and the size in bits of the encoded samples.
+func (enc *Encoder) analyze(samples []int32, bps uint) (frame.SubHeader, uint64, error) {
+	// constant prediction; wasted bits-per-sample are never used since
+	// the flag and unary coded count would cost as many bits as they save
+	if isConstant(samples) {
+		return frame.SubHeader{Pred: frame.PredConstant}, uint64(bps), nil
+	}
+
+	// analyze the audio samples with wasted bits-per-sample removed
+	wasted := wastedBits(samples, bps)
+	if wasted > 0 {
+		shifted := make([]int32, len(samples))
+		for i, sample := range samples {
+			shifted[i] = sample >> wasted
+		}
+		samples = shifted
+		bps -= wasted
+	}
+
+	// verbatim prediction is used as fallback
+	best := frame.SubHeader{Pred: frame.PredVerbatim}
+	bestBits := uint64(len(samples)) * uint64(bps)
+
+	// fixed prediction; skipped if the residuals of
+	// 32-bit audio samples overflow 32 bits
+	order, _ := bestFixedOrder(samples)
+	hdr := frame.SubHeader{Pred: frame.PredFixed, Order: order}
+	if residuals, err := lpcResiduals(samples, frame.FixedCoeffs[order], 0); err == nil {
+		nbits := uint64(order)*uint64(bps) + enc.riceCoding(&hdr, residuals)
+		if nbits < bestBits {
+			best, bestBits = hdr, nbits
+		}
+	}
+
+	// FIR linear prediction
+	if hdr, nbits, ok := enc.analyzeFIR(samples, bps); ok && nbits < bestBits {
+		best, bestBits = hdr, nbits
+	}
+
+	// 1 bit wasted bits-per-sample flag, followed by the unary coded count
+	best.Wasted = wasted
+	bestBits += 1 + uint64(wasted)
+	return best, bestBits, nil
+}
+
+// riceParam returns the Rice parameter (at most maxParam) which encodes
+// the given residuals using the fewest bits, and the number of bits used.
+func riceParam(residuals []int32, maxParam uint) (param uint, nbits uint64) {
+	var sum uint64
+	for _, residual := range residuals {
+		sum += uint64(bits.EncodeZigZag(residual))
+	}
+
+	// the optimal Rice parameter is close to log2 of the mean of the folded residuals
+	var k uint
+	if n := uint64(len(residuals)); n > 0 {
+		for mean := sum / n; mean > 1 && k < maxParam; mean >>= 1 {
+			k++
+		}
+	}
+
+	param, nbits = k, riceBits(residuals, k)
+	for _, k := range []uint{k - 1, k + 1} {
+		if k > maxParam {
+			// includes wrap-around of k-1 when k is 0
+			continue
+		}
+		if n := riceBits(residuals, k); n < nbits {
+			param, nbits = k, n
+		}
+	}
+
+	return param, nbits
+}
+
+// riceBits returns the number of bits used to
+// Rice encode the given residuals using the Rice parameter k.
+func riceBits(residuals []int32, k uint) uint64 {
+	// each residual is stored as an unary coded quotient,
+	// terminated by a one bit, followed by k remainder bits
+	nbits := uint64(len(residuals)) * uint64(k+1)
+	for _, residual := range residuals {
+		nbits += uint64(bits.EncodeZigZag(residual) >> k)
+	}
+
+	return nbits
+}
+
+// bestFixedOrder returns the fixed prediction order
+// which produces residuals of the lowest energy,
+// measured as the sum of absolute residual values,
+// and the residual energy of the order.
+func bestFixedOrder(samples []int32) (order int, sum uint64) {
+	maxOrder := maxFixedOrder
+	if len(samples) <= maxOrder {
+		maxOrder = len(samples) - 1
+	}
+
+	// residuals of successive fixed prediction orders are
+	// the successive differences of the audio samples
+	var sums [maxFixedOrder + 1]uint64
+	var prev [maxFixedOrder]int64
+	for i, sample := range samples {
+		e := int64(sample)
+		for order := 0; order <= maxOrder; order++ {
+			if i >= maxOrder {
+				sums[order] += abs64(e)
+			}
+
+			if order < maxOrder {
+				e, prev[order] = e-prev[order], e
+			}
+		}
+	}
+
+	for i := 1; i <= maxOrder; i++ {
+		if sums[i] < sums[order] {
+			order = i
+		}
+	}
+
+	return order, sums[order]
+}
+
+// wastedBits returns the number of trailing zero bits shared by all audio samples,
+// which are not stored by the encoder. At least one bit-per-sample is kept.
+func wastedBits(samples []int32, bps uint) uint {
+	var x int32
+	for _, sample := range samples {
+		x |= sample
+	}
+
+	if x == 0 {
+		return 0
+	}
+
+	wasted := uint(mathbits.TrailingZeros32(uint32(x)))
+	if wasted >= bps {
+		wasted = bps - 1
+	}
+
+	return wasted
+}
+
+// isConstant reports whether all audio samples have the same value.
+func isConstant(samples []int32) bool {
+	if len(samples) == 0 {
+		return false
+	}
+
+	for _, sample := range samples[1:] {
+		if sample != samples[0] {
+			return false
+		}
+	}
+
+	return true
+}
+
+// abs64 returns the absolute value of x.
+func abs64(x int64) uint64 {
+	if x < 0 {
+		return uint64(-x)
+	}
+	return uint64(x)
+}
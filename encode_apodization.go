@@ -0,0 +1,205 @@
+package flac
+
+import (
+	"fmt"
+
+	"github.com/pchchv/flac/internal/lpc"
+)
+
+const (
+	// Apodization window functions:
+
+	// WindowTukey is a Tukey window, whose cosine-tapered region spans the fraction P of the window.
+	WindowTukey Window = iota
+	// WindowPartialTukey is a series of N Tukey windows, with the cosine-tapered
+	// fraction P, each covering part of the block and overlapping by the fraction Overlap.
+	WindowPartialTukey
+	// WindowPunchoutTukey is a series of N Tukey windows, with the cosine-tapered
+	// fraction P, each covering all of the block except for one of N parts,
+	// which overlap by the fraction Overlap.
+	WindowPunchoutTukey
+	// WindowBartlett is a Bartlett window.
+	WindowBartlett
+	// WindowBartlettHann is a Bartlett-Hann window.
+	WindowBartlettHann
+	// WindowBlackman is a Blackman window.
+	WindowBlackman
+	// WindowBlackmanHarris is a 4-term Blackman-Harris window with 92 dB sidelobe attenuation.
+	WindowBlackmanHarris
+	// WindowConnes is a Connes window.
+	WindowConnes
+	// WindowFlattop is a flat top window.
+	WindowFlattop
+	// WindowGauss is a Gaussian window with the standard deviation P, between 0 and 0.5.
+	WindowGauss
+	// WindowHamming is a Hamming window.
+	WindowHamming
+	// WindowHann is a Hann window.
+	WindowHann
+	// WindowKaiserBessel is a Kaiser-Bessel window.
+	WindowKaiserBessel
+	// WindowNuttall is a Nuttall window.
+	WindowNuttall
+	// WindowRectangle is a rectangular window.
+	WindowRectangle
+	// WindowTriangle is a triangular window.
+	WindowTriangle
+	// WindowWelch is a Welch window.
+	WindowWelch
+
+	// maxWindows is the highest number of windows tried by LPC analysis.
+	maxWindows = 32
+	// maxCachedWindows is the highest number of block sizes
+	// whose windows are cached by the encoder.
+	maxCachedWindows = 16
+)
+
+// Window specifies the window function of an apodization.
+type Window uint8
+
+// Apodization specifies a window applied to the audio samples of
+// a subframe before LPC analysis. Every window of the encoder options
+// is tried, and the FIR linear prediction which encodes the subframe
+// using the fewest bits is kept.
+//
+// Use Tukey, PartialTukey and PunchoutTukey to get the parameterized
+// windows with the defaults of the reference encoder.
+type Apodization struct {
+	// Window function.
+	Window Window
+	// Cosine-tapered fraction of Tukey windows,
+	// and standard deviation of Gaussian windows.
+	P float64
+	// Number of partial and punchout Tukey windows.
+	N int
+	// Fraction by which partial and punchout Tukey windows overlap; below 1.
+	Overlap float64
+}
+
+// Tukey returns the apodization of a Tukey window, whose cosine-tapered
+// region spans the fraction p of the window; tukey(p) of the reference encoder.
+func Tukey(p float64) Apodization {
+	return Apodization{Window: WindowTukey, P: p}
+}
+
+// PartialTukey returns the apodization of n partial Tukey windows;
+// partial_tukey(n) of the reference encoder.
+func PartialTukey(n int) Apodization {
+	return Apodization{Window: WindowPartialTukey, P: 0.2, N: n, Overlap: 0.1}
+}
+
+// PunchoutTukey returns the apodization of n punchout Tukey windows;
+// punchout_tukey(n) of the reference encoder.
+func PunchoutTukey(n int) Apodization {
+	return Apodization{Window: WindowPunchoutTukey, P: 0.2, N: n, Overlap: 0.2}
+}
+
+// count returns the number of windows of the apodization.
+func (a Apodization) count() int {
+	if (a.Window == WindowPartialTukey || a.Window == WindowPunchoutTukey) && a.N > 1 {
+		return a.N
+	}
+
+	return 1
+}
+
+// validate reports whether the apodization is valid.
+func (a Apodization) validate() error {
+	switch {
+	case a.Window > WindowWelch:
+		return fmt.Errorf("invalid apodization window %d", a.Window)
+	case a.Window == WindowGauss && (a.P <= 0 || a.P > 0.5):
+		return fmt.Errorf("invalid standard deviation %v of Gaussian window; expected between 0 and 0.5", a.P)
+	case a.N < 0:
+		return fmt.Errorf("invalid number of Tukey windows %d", a.N)
+	case a.Overlap < 0 || a.Overlap >= 1:
+		return fmt.Errorf("invalid Tukey window overlap %v; expected between 0 and 1", a.Overlap)
+	}
+
+	return nil
+}
+
+// windows returns the windows of the apodization for a block of n samples.
+func (a Apodization) windows(n int) [][]float64 {
+	switch a.Window {
+	case WindowPartialTukey, WindowPunchoutTukey:
+		if a.N <= 1 {
+			return [][]float64{lpc.Tukey(n, a.P)}
+		}
+
+		// parts overlap by the given fraction of their length
+		units := 1/(1-a.Overlap) - 1
+		parts := float64(a.N) + units
+		windows := make([][]float64, a.N)
+		for i := range windows {
+			start := float64(i) / parts
+			end := (float64(i) + 1 + units) / parts
+			if a.Window == WindowPartialTukey {
+				windows[i] = lpc.PartialTukey(n, a.P, start, end)
+			} else {
+				windows[i] = lpc.PunchoutTukey(n, a.P, start, end)
+			}
+		}
+
+		return windows
+	}
+
+	var w []float64
+	switch a.Window {
+	case WindowTukey:
+		w = lpc.Tukey(n, a.P)
+	case WindowBartlett:
+		w = lpc.Bartlett(n)
+	case WindowBartlettHann:
+		w = lpc.BartlettHann(n)
+	case WindowBlackman:
+		w = lpc.Blackman(n)
+	case WindowBlackmanHarris:
+		w = lpc.BlackmanHarris(n)
+	case WindowConnes:
+		w = lpc.Connes(n)
+	case WindowFlattop:
+		w = lpc.Flattop(n)
+	case WindowGauss:
+		w = lpc.Gauss(n, a.P)
+	case WindowHamming:
+		w = lpc.Hamming(n)
+	case WindowHann:
+		w = lpc.Hann(n)
+	case WindowKaiserBessel:
+		w = lpc.KaiserBessel(n)
+	case WindowNuttall:
+		w = lpc.Nuttall(n)
+	case WindowTriangle:
+		w = lpc.Triangle(n)
+	case WindowWelch:
+		w = lpc.Welch(n)
+	default:
+		w = lpc.Rectangle(n)
+	}
+
+	return [][]float64{w}
+}
+
+// lpcWindows returns the windows of the encoder options for a block of n samples,
+// which are cached for each block size. It is safe for concurrent use.
+func (enc *Encoder) lpcWindows(n int) [][]float64 {
+	enc.windowsMu.Lock()
+	defer enc.windowsMu.Unlock()
+	if windows, ok := enc.windows[n]; ok {
+		return windows
+	}
+
+	// limit the cache for streams of many different block sizes
+	if enc.windows == nil || len(enc.windows) >= maxCachedWindows {
+		enc.windows = make(map[int][][]float64)
+	}
+
+	var windows [][]float64
+	for _, a := range enc.opts.Apodizations {
+		windows = append(windows, a.windows(n)...)
+	}
+	enc.windows[n] = windows
+
+	return windows
+}
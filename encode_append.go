@@ -0,0 +1,201 @@
+package flac
+
+import (
+	"bytes"
+	"crypto/md5"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+
+	"github.com/pchchv/flac/frame"
+)
+
+// NewAppendEncoder returns a new FLAC encoder which appends frames to the
+// end of the existing FLAC stream of rws, using the given encoder options.
+// The StreamInfo metadata block and the metadata blocks of the stream are
+// parsed from rws, and frame numbering carries on from the last frame.
+// On Close, StreamInfo is rewritten with the totals of the combined stream;
+// the existing SeekTable, if any, is left unchanged.
+//
+// If md5sum is nil, the existing audio samples are decoded to seed the MD5
+// running hash of the encoder. Otherwise, md5sum is used as the running hash of
+// the existing audio samples (e.g. a crypto/md5 hash restored through
+// encoding.BinaryUnmarshaler), and only the first frame header is parsed;
+// StreamInfo must then specify the number of samples of the stream,
+// and the minimum block size of StreamInfo is assumed to account for
+// the last frame of a stream of variable block size.
+//
+// Appended frames must use the blocking strategy of the existing stream;
+// the block size of a fixed block size stream may not change, and its
+// last frame must be a complete block.
+func NewAppendEncoder(rws io.ReadWriteSeeker, opts *EncoderOptions, md5sum hash.Hash) (*Encoder, error) {
+	// the StreamInfo metadata block is rewritten directly after the FLAC signature
+	if _, err := rws.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+
+	var buf [4]byte
+	if _, err := io.ReadFull(rws, buf[:]); err != nil {
+		return nil, err
+	}
+
+	if !bytes.Equal(buf[:], flacSignature) {
+		return nil, fmt.Errorf("unable to append to stream; invalid FLAC signature, expected %q, got %q", flacSignature, buf)
+	}
+
+	if _, err := rws.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+
+	stream, err := Parse(rws)
+	if err != nil {
+		return nil, err
+	}
+
+	enc := &Encoder{
+		Stream: &Stream{
+			Info:   stream.Info,
+			Blocks: stream.Blocks,
+		},
+		w:            rws,
+		md5sum:       md5sum,
+		blockSizeMin: stream.Info.BlockSizeMin,
+		blockSizeMax: stream.Info.BlockSizeMax,
+		frameSizeMin: stream.Info.FrameSizeMin,
+		frameSizeMax: stream.Info.FrameSizeMax,
+	}
+
+	var first *frame.Frame
+	if md5sum == nil {
+		enc.md5sum = md5.New()
+		first, err = enc.decodeExisting(stream)
+	} else {
+		first, err = enc.skipExisting(stream)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	if first != nil {
+		// frame sizes of the existing stream remain unknown if not stated
+		enc.frameSizeUnknown = enc.frameSizeMin == 0 || enc.frameSizeMax == 0
+		if err := enc.checkAppendBlocking(first, opts); err != nil {
+			return nil, err
+		}
+	}
+
+	if opts != nil {
+		if enc.opts, err = newEncoderOptions(enc.Info, opts); err != nil {
+			return nil, err
+		}
+		enc.analysis = true
+
+		if enc.opts.seekInterval(enc.Info.SampleRate) > 0 {
+			return nil, errors.New("unable to reserve seek table; not supported when appending to stream")
+		}
+	}
+
+	if _, err := rws.Seek(0, io.SeekEnd); err != nil {
+		return nil, err
+	}
+
+	return enc, nil
+}
+
+// decodeExisting decodes the audio frames of the existing stream to be
+// appended to, adding its audio samples to the MD5 running hash of the encoder.
+// It returns the first frame of the stream; nil if the stream has no frames.
+func (enc *Encoder) decodeExisting(stream *Stream) (first *frame.Frame, err error) {
+	for {
+		f, err := stream.ParseNext()
+		if err != nil {
+			if err == io.EOF {
+				break
+			}
+			return nil, err
+		}
+
+		if first == nil {
+			first = f
+		}
+
+		f.Hash(enc.md5sum)
+
+		// the block size of the previous frame counts towards the minimum
+		if last := enc.lastBlockSize; last != 0 && (enc.blockSizeMin == 0 || last < enc.blockSizeMin) {
+			enc.blockSizeMin = last
+		}
+		enc.lastBlockSize = f.BlockSize
+
+		if f.BlockSize > enc.blockSizeMax {
+			enc.blockSizeMax = f.BlockSize
+		}
+
+		enc.nframes++
+		enc.nsamples += uint64(f.BlockSize)
+		enc.curNum = f.Num + 1
+		if !f.HasFixedBlockSize {
+			enc.curNum = f.Num + uint64(f.BlockSize)
+		}
+	}
+
+	if enc.Info.NSamples != 0 && enc.Info.NSamples != enc.nsamples {
+		return nil, fmt.Errorf("unable to append to stream; sample count mismatch, expected %d, got %d", enc.Info.NSamples, enc.nsamples)
+	}
+
+	return first, nil
+}
+
+// skipExisting determines the frame numbering of the existing stream to be
+// appended to from StreamInfo and the first frame header, without decoding
+// the audio frames. It returns the first frame header of the stream;
+// nil if the stream has no frames.
+func (enc *Encoder) skipExisting(stream *Stream) (*frame.Frame, error) {
+	first, err := stream.Next()
+	if err != nil {
+		if err == io.EOF {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	nsamples := enc.Info.NSamples
+	if nsamples == 0 {
+		return nil, errors.New("unable to append to stream; unknown number of samples in StreamInfo")
+	}
+
+	enc.nsamples = nsamples
+	if !first.HasFixedBlockSize {
+		enc.curNum = nsamples
+		return first, nil
+	}
+
+	blockSize := uint64(first.BlockSize)
+	enc.nframes = (nsamples + blockSize - 1) / blockSize
+	enc.curNum = enc.nframes
+	enc.lastBlockSize = uint16(nsamples - (enc.nframes-1)*blockSize)
+	return first, nil
+}
+
+// checkAppendBlocking reports whether frames encoded using the given encoder
+// options may be appended to the existing stream, given its first frame.
+func (enc *Encoder) checkAppendBlocking(first *frame.Frame, opts *EncoderOptions) error {
+	if !first.HasFixedBlockSize {
+		if opts != nil && !opts.VariableBlockSize {
+			return errors.New("unable to append fixed block size frames to stream of variable block size")
+		}
+		return nil
+	}
+
+	if enc.lastBlockSize != first.BlockSize {
+		return fmt.Errorf("unable to append to stream of fixed block size; last block holds %d samples, expected %d", enc.lastBlockSize, first.BlockSize)
+	}
+
+	if opts != nil && (opts.VariableBlockSize || opts.BlockSize != first.BlockSize) {
+		return fmt.Errorf("unable to append to stream of fixed block size; expected block size %d", first.BlockSize)
+	}
+
+	return nil
+}
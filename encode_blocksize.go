@@ -0,0 +1,99 @@
+package flac
+
+import (
+	"github.com/pchchv/flac/frame"
+)
+
+const (
+	// maxSplitDepth is the number of times a block is halved by
+	// variable block size encoding; blocks are split into at most 8 parts.
+	maxSplitDepth = 3
+	// minVariableBlockSize is the smallest block size produced by
+	// splitting blocks in variable block size encoding.
+	minVariableBlockSize = 256
+	// frameOverheadBits is the estimated size in bits of the frame header,
+	// padding and CRC-16 of a frame, used to compare trial encodings.
+	frameOverheadBits = 10 * 8
+)
+
+// minBlockSize returns the smallest block size of frames produced by WriteSamples,
+// excluding the final partial block.
+func (opts *EncoderOptions) minBlockSize() uint16 {
+	if !opts.VariableBlockSize {
+		return opts.BlockSize
+	}
+
+	n := opts.BlockSize
+	for depth := 0; depth < maxSplitDepth && n/2 >= minVariableBlockSize; depth++ {
+		n /= 2
+	}
+
+	return n
+}
+
+// splitBlock determines the block sizes of the n buffered audio samples
+// starting at offset, which are estimated to encode using the fewest bits,
+// by trial encoding the block and recursively its halves.
+// It returns the block sizes and their estimated size in bits.
+func (enc *Encoder) splitBlock(offset, n, depth int) ([]int, uint64, error) {
+	nbits, err := enc.trialBits(offset, n)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	if depth == maxSplitDepth || n/2 < minVariableBlockSize {
+		return []int{n}, nbits, nil
+	}
+
+	left, leftBits, err := enc.splitBlock(offset, n/2, depth+1)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	right, rightBits, err := enc.splitBlock(offset+n/2, n-n/2, depth+1)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	// merge the halves unless splitting saves bits
+	if leftBits+rightBits < nbits {
+		return append(left, right...), leftBits + rightBits, nil
+	}
+
+	return []int{n}, nbits, nil
+}
+
+// trialBits returns the estimated size in bits of a frame holding
+// the n buffered audio samples starting at offset.
+func (enc *Encoder) trialBits(offset, n int) (uint64, error) {
+	f := enc.pendingFrame(offset, n, false)
+	nbits, err := enc.analyzeFrame(f)
+	if err != nil {
+		return 0, err
+	}
+
+	return nbits + frameOverheadBits, nil
+}
+
+// pendingFrame returns a frame holding the n buffered audio samples starting at offset.
+// The subframes of the frame share the underlying array of the buffers.
+func (enc *Encoder) pendingFrame(offset, n int, fixed bool) *frame.Frame {
+	f := &frame.Frame{
+		Header: frame.Header{
+			HasFixedBlockSize: fixed,
+			BlockSize:         uint16(n),
+			Channels:          frame.Channels(enc.Info.NChannels - 1),
+			BitsPerSample:     enc.Info.BitsPerSample,
+		},
+	}
+
+	for _, samples := range enc.pending {
+		f.Subframes = append(f.Subframes, &frame.Subframe{
+			SubHeader: frame.SubHeader{Pred: frame.PredVerbatim},
+			Samples:   samples[offset : offset+n],
+			NSamples:  n,
+		})
+	}
+
+	return f
+}
@@ -1,6 +1,7 @@
 package flac
 
 import (
+	"bytes"
 	"encoding/binary"
 	"fmt"
 	"io"
@@ -24,7 +25,7 @@ func encodeFrameHeaderBitsPerSample(bw *bitio.Writer, bps uint8) error {
 	//    100 : 16 bits per sample
 	//    101 : 20 bits per sample
 	//    110 : 24 bits per sample
-	//    111 : reserved
+	//    111 : 32 bits per sample
 	var bits uint64
 	switch bps {
 	case 0:
@@ -45,6 +46,9 @@ func encodeFrameHeaderBitsPerSample(bw *bitio.Writer, bps uint8) error {
 	case 24:
 		// 110 : 24 bits per sample
 		bits = 0x6
+	case 32:
+		// 111 : 32 bits per sample
+		bits = 0x7
 	default:
 		return fmt.Errorf("support for sample size %v not yet implemented", bps)
 	}
@@ -331,7 +335,57 @@ func (enc *Encoder) encodeFrameHeader(w io.Writer, hdr frame.Header) error {
 
 // WriteFrame encodes the given audio frame to the output stream.
 // The Num field of the frame header is automatically calculated by the encoder.
+//
+// In prediction analysis mode, the channel assignment and subframe headers
+// of the encoded frame are determined by the encoder, without modifying
+// those of the given frame.
+//
+// In verify mode, the encoded frame is decoded and compared with
+// the input frame before it is written, and a *VerifyError is
+// returned on mismatch.
+//
+// If the Workers encoder option is above 1, the frame is copied and
+// encoded concurrently with other frames; it is written to the output stream
+// by a later call to WriteFrame or by Close, and errors encountered
+// while encoding it may be returned by these. The workers are stopped on the
+// first such error, and the frames queued after the failed frame are discarded.
+//
+// Once a frame could not be encoded or written, the output stream is
+// incomplete; the error is returned by every subsequent call to WriteFrame,
+// WriteSamples, WriteSamplesPlanar and Close.
 func (enc *Encoder) WriteFrame(f *frame.Frame) error {
+	if enc.err != nil {
+		return enc.err
+	}
+
+	if err := enc.prepareFrame(f); err != nil {
+		return err
+	}
+
+	if enc.opts != nil && enc.opts.Workers > 1 {
+		return enc.queueFrame(f)
+	}
+
+	buf, err := enc.encodeVerifiedFrame(f, enc.nframes-1)
+	if err != nil {
+		return enc.fail(err)
+	}
+
+	if err := enc.commitFrame(buf, f.Subframes[0].NSamples); err != nil {
+		return enc.fail(err)
+	}
+
+	return nil
+}
+
+// prepareFrame validates the given audio frame and updates the
+// frame number, sample count, block size range and MD5 running hash
+// of the encoder. Frames must be prepared in stream order.
+func (enc *Encoder) prepareFrame(f *frame.Frame) error {
+	if enc.analysis {
+		enc.fillFrameHeader(f)
+	}
+
 	// sanity checks
 	nchannels := int(enc.Info.NChannels)
 	if nchannels != len(f.Subframes) {
@@ -349,11 +403,20 @@ func (enc *Encoder) WriteFrame(f *frame.Frame) error {
 		return fmt.Errorf("channel count mismatch; expected %d, got %d", nchannels, f.Channels.Count())
 	}
 
-	// create a new CRC-16 hash writer which adds the data from all write operations to a running hash
-	h := crc16.NewIBM()
-	hw := io.MultiWriter(h, enc.w)
+	if enc.opts != nil && enc.opts.Subset {
+		if err := checkSubsetFrame(f.Header, enc.nframes); err != nil {
+			return err
+		}
 
-	// encode frame header
+		// the subframe headers of the caller are encoded unless analyzed
+		if !enc.analysis {
+			if err := checkSubsetSubframes(f, enc.nframes); err != nil {
+				return err
+			}
+		}
+	}
+
+	enc.nframes++
 	f.Num = enc.curNum
 	if f.HasFixedBlockSize {
 		enc.curNum++
@@ -363,25 +426,71 @@ func (enc *Encoder) WriteFrame(f *frame.Frame) error {
 
 	enc.nsamples += uint64(nsamplesPerChannel)
 	blockSize := uint16(nsamplesPerChannel)
-	if enc.blockSizeMin == 0 || blockSize < enc.blockSizeMin {
-		enc.blockSizeMin = blockSize
+	// the minimum block size excludes the last block of the stream,
+	// which is only known to be the last once the next frame is written
+	if last := enc.lastBlockSize; last != 0 && (enc.blockSizeMin == 0 || last < enc.blockSizeMin) {
+		enc.blockSizeMin = last
 	}
+	enc.lastBlockSize = blockSize
 
 	if enc.blockSizeMax == 0 || blockSize > enc.blockSizeMax {
 		enc.blockSizeMax = blockSize
 	}
 
 	f.Hash(enc.md5sum)
-	if err := enc.encodeFrameHeader(hw, f.Header); err != nil {
-		return err
+	return nil
+}
+
+// encodeFrame encodes the given prepared audio frame,
+// and returns the encoded frame; from the sync code through the CRC-16.
+// It does not modify the state of the encoder, and
+// may be called concurrently for different frames.
+func (enc *Encoder) encodeFrame(f *frame.Frame) ([]byte, error) {
+	if enc.analysis {
+		// the channel assignment and subframe headers are determined
+		// on a copy of the headers, leaving the frame of the caller unchanged
+		f = shareFrame(f)
+		if _, err := enc.analyzeFrame(f); err != nil {
+			return nil, err
+		}
+	}
+
+	// encode frame header
+	buf := new(bytes.Buffer)
+	if err := enc.encodeFrameHeader(buf, f.Header); err != nil {
+		return nil, err
+	}
+
+	// encode subframes
+	bw := bitio.NewWriter(buf)
+	if hasWideSide(f.Header) {
+		if err := encodeWideStereo(bw, f); err != nil {
+			return nil, err
+		}
+	} else if err := encodeSubframes(bw, f); err != nil {
+		return nil, err
+	}
+
+	// zero-padding to byte alignment
+	// flush pending writes to subframe
+	if _, err := bw.Align(); err != nil {
+		return nil, err
 	}
 
+	// CRC-16 (polynomial = x^16 + x^15 + x^2 + x^0, initialized with 0)
+	// of everything before the crc,
+	// back to and including the frame header sync code
+	crc := crc16.ChecksumIBM(buf.Bytes())
+	return binary.BigEndian.AppendUint16(buf.Bytes(), crc), nil
+}
+
+// encodeSubframes encodes the subframes of the given audio frame,
+// writing to bw.
+func encodeSubframes(bw *bitio.Writer, f *frame.Frame) error {
 	// inter-channel decorrelation of subframe samples
 	f.Decorrelate()
 	defer f.Correlate() // NOTE: revert decorrelation of audio samples after encoding is done (to make encode non-destructive)
 
-	// encode subframes
-	bw := bitio.NewWriter(hw)
 	for channel, subframe := range f.Subframes {
 		// side channel requires an extra bit per sample when using inter-channel decorrelation
 		bps := uint(f.BitsPerSample)
@@ -403,19 +512,32 @@ func (enc *Encoder) WriteFrame(f *frame.Frame) error {
 		}
 	}
 
-	// zero-padding to byte alignment
-	// flush pending writes to subframe
-	if _, err := bw.Align(); err != nil {
+	return nil
+}
+
+// commitFrame writes the given encoded audio frame,
+// holding nsamples samples per channel, to the output stream.
+func (enc *Encoder) commitFrame(buf []byte, nsamples int) error {
+	if _, err := enc.w.Write(buf); err != nil {
 		return err
 	}
 
-	// CRC-16 (polynomial = x^16 + x^15 + x^2 + x^0, initialized with 0)
-	// of everything before the crc,
-	// back to and including the frame header sync code
-	crc := h.Sum16()
-	if err := binary.Write(enc.w, binary.BigEndian, crc); err != nil {
-		return err
+	if enc.seekTable != nil {
+		enc.addSeekPoints(enc.frameSample, enc.frameOffset, nsamples)
+	}
+
+	// the frame size covers the frame header, subframes, padding and CRC-16,
+	// as counted by the encoder rather than by the underlying writer
+	frameSize := uint32(len(buf))
+	if enc.frameSizeMin == 0 || frameSize < enc.frameSizeMin {
+		enc.frameSizeMin = frameSize
+	}
+
+	if frameSize > enc.frameSizeMax {
+		enc.frameSizeMax = frameSize
 	}
 
+	enc.frameOffset += uint64(len(buf))
+	enc.frameSample += uint64(nsamples)
 	return nil
 }
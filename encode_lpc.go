@@ -0,0 +1,190 @@
+package flac
+
+import (
+	"sort"
+
+	"github.com/pchchv/flac/frame"
+	"github.com/pchchv/flac/internal/lpc"
+)
+
+const (
+	// minQLPCoeffPrec and maxQLPCoeffPrec are the lowest and highest precision
+	// in bits of quantized FIR predictor coefficients tried by prediction analysis;
+	// the 4-bit precision pattern 1111 is invalid.
+	minQLPCoeffPrec = 5
+	maxQLPCoeffPrec = 15
+	// orderCandidates is the number of prediction orders with the lowest
+	// estimated size whose residuals are Rice coded by prediction analysis,
+	// unless ExhaustiveModelSearch is set.
+	orderCandidates = 3
+)
+
+// analyzeFIR determines the FIR linear prediction which encodes
+// the given audio samples using the fewest bits,
+// trying the LPC coefficients of each apodization window.
+// It returns the subframe header and size in bits of the prediction,
+// and false if no FIR linear prediction could be computed.
+func (enc *Encoder) analyzeFIR(samples []int32, bps uint) (frame.SubHeader, uint64, bool) {
+	n := len(samples)
+	maxOrder := enc.opts.MaxLPCOrder
+	if maxOrder >= n {
+		maxOrder = n - 1
+	}
+
+	if maxOrder < 1 {
+		return frame.SubHeader{}, 0, false
+	}
+
+	var best frame.SubHeader
+	var bestBits uint64
+	ok := false
+	x := make([]float64, n)
+	for _, window := range enc.lpcWindows(n) {
+		hdr, nbits, found := enc.analyzeWindow(samples, bps, x, window, maxOrder)
+		if found && (!ok || nbits < bestBits) {
+			best, bestBits, ok = hdr, nbits, true
+		}
+	}
+
+	return best, bestBits, ok
+}
+
+// analyzeWindow determines the FIR linear prediction of up to maxOrder which
+// encodes the given audio samples using the fewest bits, based on the LPC
+// coefficients of the samples with the given window applied.
+// The buffer x holds the windowed samples.
+func (enc *Encoder) analyzeWindow(samples []int32, bps uint, x, window []float64, maxOrder int) (frame.SubHeader, uint64, bool) {
+	// windowed autocorrelation
+	n := len(samples)
+	for i, sample := range samples {
+		x[i] = float64(sample) * window[i]
+	}
+
+	autoc := lpc.Autocorrelation(x, maxOrder)
+	if autoc[0] == 0 {
+		return frame.SubHeader{}, 0, false
+	}
+
+	// estimate the best prediction order from the prediction error
+	coeffs, errs := lpc.Coefficients(autoc, maxOrder)
+	if len(coeffs) == 0 {
+		return frame.SubHeader{}, 0, false
+	}
+
+	basePrec := qlpCoeffPrec(n, bps)
+	estimates := make([]float64, len(errs))
+	orders := make([]int, len(errs))
+	for i, err := range errs {
+		order := i + 1
+		estimates[i] = lpc.ExpectedBits(err, n)*float64(n-order) + float64(order)*float64(bps+basePrec)
+		orders[i] = i
+	}
+	sort.SliceStable(orders, func(i, j int) bool {
+		return estimates[orders[i]] < estimates[orders[j]]
+	})
+
+	// Rice code the residuals of the most promising orders
+	// to determine the best prediction order
+	candidates := orderCandidates
+	if enc.opts.ExhaustiveModelSearch || candidates > len(orders) {
+		candidates = len(orders)
+	}
+
+	best, bestBits := -1, uint64(0)
+	for _, i := range orders[:candidates] {
+		if _, nbits, err := enc.firSubHeader(samples, bps, coeffs[i], basePrec); err == nil && (best < 0 || nbits < bestBits) {
+			best, bestBits = i, nbits
+		}
+	}
+
+	if best < 0 {
+		best = orders[0]
+	}
+
+	// search the precision of the quantized coefficients
+	var hdr frame.SubHeader
+	var hdrBits uint64
+	ok := false
+	for prec := basePrec - 1; prec <= basePrec+1; prec++ {
+		if prec < minQLPCoeffPrec || prec > maxQLPCoeffPrec {
+			continue
+		}
+
+		h, nbits, err := enc.firSubHeader(samples, bps, coeffs[best], prec)
+		if err != nil {
+			continue
+		}
+
+		if !ok || nbits < hdrBits {
+			hdr, hdrBits, ok = h, nbits, true
+		}
+	}
+
+	return hdr, hdrBits, ok
+}
+
+// firSubHeader quantizes the given LPC coefficients with the specified precision,
+// and returns the subframe header and size in bits of the resulting FIR linear prediction.
+func (enc *Encoder) firSubHeader(samples []int32, bps uint, coeffs []float64, prec uint) (frame.SubHeader, uint64, error) {
+	qcoeffs, shift, err := lpc.Quantize(coeffs, prec)
+	if err != nil {
+		return frame.SubHeader{}, 0, err
+	}
+
+	hdr := frame.SubHeader{
+		Pred:       frame.PredFIR,
+		Order:      len(qcoeffs),
+		CoeffPrec:  prec,
+		CoeffShift: shift,
+		Coeffs:     qcoeffs,
+	}
+	residuals, err := lpcResiduals(samples, qcoeffs, shift)
+	if err != nil {
+		return frame.SubHeader{}, 0, err
+	}
+
+	// warm-up samples, 4 bits of precision,
+	// 5 bits of shift and the quantized coefficients
+	nbits := uint64(hdr.Order)*uint64(bps) + 4 + 5 + uint64(hdr.Order)*uint64(prec)
+	nbits += enc.riceCoding(&hdr, residuals)
+	return hdr, nbits, nil
+}
+
+// lpcResiduals returns the residuals of the given audio samples,
+// predicted using the given coefficients and shift.
+func lpcResiduals(samples []int32, coeffs []int32, shift int32) ([]int32, error) {
+	subframe := &frame.Subframe{
+		SubHeader: frame.SubHeader{Order: len(coeffs)},
+		Samples:   samples,
+		NSamples:  len(samples),
+	}
+	return getLPCResiduals(subframe, coeffs, shift)
+}
+
+// qlpCoeffPrec returns the default precision in bits of quantized FIR predictor coefficients,
+// based on the block size and the sample size of the subframe.
+func qlpCoeffPrec(blockSize int, bps uint) uint {
+	if bps < 16 {
+		if prec := 2 + bps/2; prec > minQLPCoeffPrec {
+			return prec
+		}
+		return minQLPCoeffPrec
+	}
+
+	switch {
+	case blockSize <= 192:
+		return 7
+	case blockSize <= 384:
+		return 8
+	case blockSize <= 576:
+		return 9
+	case blockSize <= 1152:
+		return 10
+	case blockSize <= 2304:
+		return 11
+	case blockSize <= 4608:
+		return 12
+	default:
+		return 13
+	}
+}
@@ -0,0 +1,160 @@
+package flac
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/pchchv/flac/internal/lpc"
+)
+
+const (
+	// Stereo decorrelation modes:
+
+	// StereoIndependent encodes the left and right channels of stereo frames independently.
+	StereoIndependent StereoMode = iota
+	// StereoAdaptive estimates the cost of left/right, left/side,
+	// side/right and mid/side stereo and uses the cheapest.
+	StereoAdaptive
+	// StereoExhaustive encodes the left, right, mid and side channels of
+	// stereo frames and uses the cheapest channel assignment.
+	StereoExhaustive
+
+	// DefaultLevel is the compression level used by the reference encoder by default.
+	DefaultLevel = 5
+	// MaxLevel is the highest compression level.
+	MaxLevel = 8
+)
+
+// presets maps from compression level to the encoder options
+// used by the equivalent preset (-0 through -8) of the reference encoder.
+var presets = [...]EncoderOptions{
+	0: {BlockSize: 1152, MaxLPCOrder: 0, MaxPartOrder: 3, StereoMode: StereoIndependent, Apodizations: []Apodization{Tukey(0.5)}},
+	1: {BlockSize: 1152, MaxLPCOrder: 0, MaxPartOrder: 3, StereoMode: StereoAdaptive, Apodizations: []Apodization{Tukey(0.5)}},
+	2: {BlockSize: 1152, MaxLPCOrder: 0, MaxPartOrder: 3, StereoMode: StereoExhaustive, Apodizations: []Apodization{Tukey(0.5)}},
+	3: {BlockSize: 4096, MaxLPCOrder: 6, MaxPartOrder: 4, StereoMode: StereoIndependent, Apodizations: []Apodization{Tukey(0.5)}},
+	4: {BlockSize: 4096, MaxLPCOrder: 8, MaxPartOrder: 4, StereoMode: StereoAdaptive, Apodizations: []Apodization{Tukey(0.5)}},
+	5: {BlockSize: 4096, MaxLPCOrder: 8, MaxPartOrder: 5, StereoMode: StereoExhaustive, Apodizations: []Apodization{Tukey(0.5)}},
+	6: {BlockSize: 4096, MaxLPCOrder: 8, MaxPartOrder: 6, StereoMode: StereoExhaustive, Apodizations: []Apodization{Tukey(0.5), PartialTukey(2)}},
+	7: {BlockSize: 4096, MaxLPCOrder: 12, MaxPartOrder: 6, StereoMode: StereoExhaustive, Apodizations: []Apodization{Tukey(0.5), PartialTukey(2)}},
+	8: {BlockSize: 4096, MaxLPCOrder: 12, MaxPartOrder: 6, StereoMode: StereoExhaustive, Apodizations: []Apodization{Tukey(0.5), PartialTukey(2), PunchoutTukey(3)}},
+}
+
+// StereoMode specifies how the encoder selects the
+// inter-channel decorrelation of stereo frames.
+type StereoMode uint8
+
+// EncoderOptions specifies how the encoder analyzes audio samples to
+// determine the prediction method, prediction order and residual coding
+// parameters of each subframe.
+//
+// Use LevelOptions to get the options of a compression level,
+// and override individual fields as needed.
+type EncoderOptions struct {
+	// Block size in inter-channel samples of frames produced by the encoder,
+	// and the largest block size if VariableBlockSize is set.
+	// It is also used to derive the minimum and maximum block size of
+	// StreamInfo when these are unset.
+	BlockSize uint16
+	// Specifies if frames produced by WriteSamples and WriteSamplesPlanar
+	// use a variable block size, chosen from the content of each block by
+	// trial encoding of its halves, quarters and eighths;
+	// short blocks around transients and long blocks for stationary passages.
+	VariableBlockSize bool
+	// Highest FIR linear prediction order tried;
+	// between 0 and 32, where 0 disables FIR linear prediction.
+	MaxLPCOrder int
+	// Specifies if the residuals of every FIR linear prediction order up to
+	// MaxLPCOrder are Rice coded to select the order; otherwise only the
+	// few orders of the lowest size estimated from the prediction error are.
+	ExhaustiveModelSearch bool
+	// Lowest and highest Rice partition order tried; between 0 and 15.
+	MinPartOrder, MaxPartOrder int
+	// Inter-channel decorrelation of stereo frames.
+	StereoMode StereoMode
+	// Windows applied to the audio samples before LPC analysis;
+	// at most 32 windows in total. Defaults to Tukey(0.5) if empty.
+	Apodizations []Apodization
+	// Number of goroutines encoding frames concurrently;
+	// frames are encoded sequentially if at most 1.
+	// The output is identical regardless of the number of workers.
+	// Close must always be called to stop the workers,
+	// even if encoding failed.
+	Workers int
+	// Interval between the seek points of a SeekTable metadata block
+	// reserved by the encoder; the seek points are placeholders until filled in
+	// by Close if the output stream is an io.WriteSeeker.
+	// SeekIntervalSamples, in samples per channel, takes precedence over SeekInterval.
+	// No seek table is reserved if both are 0. A SeekInterval shorter than
+	// one sample at the sample rate of StreamInfo is invalid.
+	SeekInterval        time.Duration
+	SeekIntervalSamples uint64
+	// Number of seek points reserved; if 0, the seek table covers the
+	// number of samples of StreamInfo, which must then be known.
+	// Seek points beyond the end of the stream remain placeholders,
+	// and no seek points are added beyond the last one reserved.
+	SeekPoints int
+	// Specifies if every encoded frame is decoded and compared with
+	// its input audio samples before it is written; see VerifyError.
+	Verify bool
+	// Specifies if the stream is restricted to the FLAC streamable subset;
+	// StreamInfo, options and frames outside of it are rejected with
+	// errors wrapping ErrNotSubset, and the sample rate is stored in each frame header.
+	Subset bool
+}
+
+// LevelOptions returns the encoder options of the given compression level,
+// between 0 (fastest) and 8 (smallest output), equivalent to
+// the presets -0 through -8 of the reference encoder.
+// Levels out of range are clamped.
+func LevelOptions(level int) *EncoderOptions {
+	if level < 0 {
+		level = 0
+	} else if level > MaxLevel {
+		level = MaxLevel
+	}
+
+	opts := presets[level].clone()
+	return &opts
+}
+
+// clone returns a copy of the encoder options, which does not
+// share the underlying array of its apodizations.
+func (opts *EncoderOptions) clone() EncoderOptions {
+	o := *opts
+	o.Apodizations = append([]Apodization(nil), opts.Apodizations...)
+	return o
+}
+
+// validate reports whether the encoder options are valid.
+func (opts *EncoderOptions) validate() error {
+	switch {
+	case opts.BlockSize < 16:
+		return fmt.Errorf("invalid block size %d; expected >= 16", opts.BlockSize)
+	case opts.MaxLPCOrder < 0 || opts.MaxLPCOrder > lpc.MaxOrder:
+		return fmt.Errorf("invalid maximum LPC order %d; expected between 0 and %d", opts.MaxLPCOrder, lpc.MaxOrder)
+	case opts.MinPartOrder < 0 || opts.MaxPartOrder > maxPartOrder || opts.MinPartOrder > opts.MaxPartOrder:
+		return fmt.Errorf("invalid Rice partition order range [%d, %d]; expected within [0, %d]", opts.MinPartOrder, opts.MaxPartOrder, maxPartOrder)
+	case opts.StereoMode > StereoExhaustive:
+		return fmt.Errorf("invalid stereo mode %d", opts.StereoMode)
+	case opts.Workers < 0:
+		return fmt.Errorf("invalid number of workers %d", opts.Workers)
+	case opts.SeekInterval < 0:
+		return fmt.Errorf("invalid seek interval %v", opts.SeekInterval)
+	case opts.SeekPoints < 0 || opts.SeekPoints > maxSeekPoints:
+		return fmt.Errorf("invalid number of seek points %d; expected at most %d", opts.SeekPoints, maxSeekPoints)
+	}
+
+	var nwindows int
+	for _, a := range opts.Apodizations {
+		if err := a.validate(); err != nil {
+			return err
+		}
+		nwindows += a.count()
+	}
+
+	if nwindows > maxWindows {
+		return fmt.Errorf("invalid number of apodization windows %d; expected at most %d", nwindows, maxWindows)
+	}
+
+	return nil
+}
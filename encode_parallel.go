@@ -0,0 +1,107 @@
+package flac
+
+import (
+	"github.com/pchchv/flac/frame"
+)
+
+// frameJob is an audio frame encoded by a worker of the encoder.
+type frameJob struct {
+	// Prepared audio frame, owned by the job, and its frame number.
+	f   *frame.Frame
+	num uint64
+	// Encoded audio frame, and the error encountered while encoding it.
+	buf []byte
+	err error
+	// Closed once the frame has been encoded.
+	done chan struct{}
+}
+
+// queueFrame copies the given prepared audio frame and hands it to the
+// workers of the encoder. Once more frames are queued than there are workers
+// to keep busy, the oldest frame is written to the output stream.
+func (enc *Encoder) queueFrame(f *frame.Frame) error {
+	if enc.jobs == nil {
+		enc.jobs = make(chan *frameJob, enc.opts.Workers)
+		enc.workers.Add(enc.opts.Workers)
+		for i := 0; i < enc.opts.Workers; i++ {
+			go enc.work(enc.jobs)
+		}
+	}
+
+	job := &frameJob{f: cloneFrame(f), num: enc.nframes - 1, done: make(chan struct{})}
+	enc.jobs <- job
+	enc.queue = append(enc.queue, job)
+	if len(enc.queue) > 2*enc.opts.Workers {
+		return enc.commitQueued()
+	}
+
+	return nil
+}
+
+// work encodes queued audio frames until the given jobs channel is closed.
+func (enc *Encoder) work(jobs <-chan *frameJob) {
+	defer enc.workers.Done()
+	for job := range jobs {
+		job.buf, job.err = enc.encodeVerifiedFrame(job.f, job.num)
+		close(job.done)
+	}
+}
+
+// commitQueued waits for the oldest queued audio frame to be encoded,
+// and writes it to the output stream. If the frame cannot be encoded or
+// written, the error is recorded and the workers of the encoder are stopped.
+func (enc *Encoder) commitQueued() error {
+	job := enc.queue[0]
+	enc.queue[0] = nil
+	enc.queue = enc.queue[1:]
+	<-job.done
+	err := job.err
+	if err == nil {
+		err = enc.commitFrame(job.buf, job.f.Subframes[0].NSamples)
+	}
+
+	if err != nil {
+		return enc.fail(err)
+	}
+
+	return nil
+}
+
+// flushQueue writes all queued audio frames to the output stream in order,
+// and stops the workers of the encoder.
+func (enc *Encoder) flushQueue() error {
+	for len(enc.queue) > 0 {
+		if err := enc.commitQueued(); err != nil {
+			return err
+		}
+	}
+
+	enc.stopWorkers()
+	return nil
+}
+
+// stopWorkers stops the workers of the encoder and waits for them to exit,
+// discarding the queued audio frames which are yet to be written.
+func (enc *Encoder) stopWorkers() {
+	if enc.jobs == nil {
+		return
+	}
+
+	close(enc.jobs)
+	enc.jobs = nil
+	enc.workers.Wait()
+	enc.queue = nil
+}
+
+// cloneFrame returns a copy of the given audio frame,
+// which does not share the audio samples of its subframes.
+func cloneFrame(f *frame.Frame) *frame.Frame {
+	clone := &frame.Frame{Header: f.Header}
+	for _, subframe := range f.Subframes {
+		s := *subframe
+		s.Samples = append([]int32(nil), subframe.Samples...)
+		clone.Subframes = append(clone.Subframes, &s)
+	}
+
+	return clone
+}
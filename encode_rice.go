@@ -0,0 +1,147 @@
+package flac
+
+import (
+	"github.com/pchchv/flac/frame"
+)
+
+const (
+	// maxRice1Param is the largest Rice parameter of the rice1 residual coding method;
+	// the 4-bit pattern 1111 is reserved as escape code.
+	maxRice1Param = 14
+	// maxRice2Param is the largest Rice parameter of the rice2 residual coding method;
+	// the 5-bit pattern 11111 is reserved as escape code.
+	maxRice2Param = 30
+	// maxPartOrder is the highest Rice partition order; stored using 4 bits.
+	maxPartOrder = 15
+	// maxEscapedBitsPerSample is the largest residual sample size of escaped partitions;
+	// stored using 5 bits.
+	maxEscapedBitsPerSample = 31
+)
+
+// riceCoding determines the residual coding method, Rice partition order and
+// Rice parameters which encode the given residuals using the fewest bits,
+// and stores them in the subframe header.
+// It returns the number of bits used to encode the residuals.
+func (enc *Encoder) riceCoding(hdr *frame.SubHeader, residuals []int32) uint64 {
+	// the block size must be evenly divisible by the number of partitions,
+	// and the first partition must hold more samples than the prediction order
+	order := hdr.Order
+	blockSize := len(residuals) + order
+	maxOrder := enc.opts.MaxPartOrder
+	for maxOrder > 0 && (blockSize%(1<<maxOrder) != 0 || blockSize>>maxOrder <= order) {
+		maxOrder--
+	}
+
+	minOrder := enc.opts.MinPartOrder
+	if minOrder > maxOrder {
+		minOrder = maxOrder
+	}
+
+	var bestBits uint64
+	for partOrder := minOrder; partOrder <= maxOrder; partOrder++ {
+		riceSubframe, method, nbits := riceSubframe(residuals, order, partOrder)
+		if partOrder == minOrder || nbits < bestBits {
+			hdr.RiceSubframe, hdr.ResidualCodingMethod, bestBits = riceSubframe, method, nbits
+		}
+	}
+
+	return bestBits
+}
+
+// riceSubframe returns the Rice-coding subframe fields and residual coding method
+// which encode the given residuals using the specified partition order,
+// and the number of bits used to encode the residuals.
+func riceSubframe(residuals []int32, order, partOrder int) (*frame.RiceSubframe, frame.ResidualCodingMethod, uint64) {
+	nparts := 1 << partOrder
+	blockSize := len(residuals) + order
+	partitions := make([]frame.RicePartition, nparts)
+	escaped := make([]bool, nparts)
+	method := frame.ResidualCodingMethodRice1
+	var nbits uint64
+	var start int
+	for i := range partitions {
+		// the first partition holds no residuals for the warm-up samples
+		nsamples := blockSize >> partOrder
+		if i == 0 {
+			nsamples -= order
+		}
+
+		part := residuals[start : start+nsamples]
+		start += nsamples
+		param, n := riceParam(part, maxRice2Param)
+		partitions[i].Param = param
+
+		// store residuals unencoded if cheaper;
+		// n bits per sample, where n follows as a 5-bit number
+		if ebps := escapedBitsPerSample(part); ebps <= maxEscapedBitsPerSample {
+			if m := 5 + uint64(ebps)*uint64(nsamples); m < n {
+				partitions[i].EscapedBitsPerSample = ebps
+				escaped[i] = true
+				n = m
+			}
+		}
+
+		if !escaped[i] && param > maxRice1Param {
+			method = frame.ResidualCodingMethodRice2
+		}
+		nbits += n
+	}
+
+	// rice1 stores 4-bit Rice parameters and rice2 5-bit Rice parameters,
+	// with the all-ones bit pattern used as escape code
+	paramSize, escapeCode := uint64(4), uint(0xF)
+	if method == frame.ResidualCodingMethodRice2 {
+		paramSize, escapeCode = 5, 0x1F
+	}
+
+	for i := range partitions {
+		if escaped[i] {
+			partitions[i].Param = escapeCode
+		}
+	}
+
+	// 2 bits: residual coding method
+	// 4 bits: partition order
+	nbits += 2 + 4 + uint64(nparts)*paramSize
+	riceSubframe := &frame.RiceSubframe{
+		PartOrder:  partOrder,
+		Partitions: partitions,
+	}
+
+	return riceSubframe, method, nbits
+}
+
+// escapedBitsPerSample returns the number of bits needed to store each of
+// the given residuals as signed two's complement integers.
+func escapedBitsPerSample(residuals []int32) uint {
+	var max uint32
+	for _, residual := range residuals {
+		// the magnitude of negative values is one less in two's complement
+		x := uint32(residual)
+		if residual < 0 {
+			x = ^x
+		}
+
+		if x > max {
+			max = x
+		}
+	}
+
+	if max == 0 {
+		// 0 bits are needed for all-zero residuals,
+		// and 1 bit if residuals are only 0 and -1
+		for _, residual := range residuals {
+			if residual != 0 {
+				return 1
+			}
+		}
+		return 0
+	}
+
+	n := uint(1) // sign bit
+	for ; max > 0; max >>= 1 {
+		n++
+	}
+
+	return n
+}
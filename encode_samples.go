@@ -0,0 +1,149 @@
+package flac
+
+import (
+	"fmt"
+)
+
+// defaultBlockSize is the block size of frames emitted by WriteSamples when
+// neither the encoder options nor StreamInfo specify a block size.
+const defaultBlockSize = 4096
+
+// WriteSamples buffers the given interleaved audio samples, and encodes a
+// frame to the output stream for each complete block of samples buffered.
+// The number of samples must be a multiple of the number of channels,
+// with the samples of all channels stored consecutively for each sample position.
+// Any final partial block is encoded by Close.
+//
+// It returns the number of samples consumed; len(samples) if err is nil.
+// Frames written by WriteSamples use a fixed block size, unless the
+// VariableBlockSize encoder option is set. Their subframes are encoded
+// using verbatim prediction, unless prediction analysis is enabled.
+// WriteSamples should not be mixed with calls to WriteFrame.
+func (enc *Encoder) WriteSamples(samples []int32) (n int, err error) {
+	if enc.err != nil {
+		return 0, enc.err
+	}
+
+	nchannels := int(enc.Info.NChannels)
+	if len(samples)%nchannels != 0 {
+		return 0, fmt.Errorf("invalid number of interleaved samples %d; expected multiple of %d channels", len(samples), nchannels)
+	}
+
+	enc.initPending()
+	blockSize := cap(enc.pending[0])
+	for n < len(samples) {
+		for len(enc.pending[0]) < blockSize && n < len(samples) {
+			for channel := range enc.pending {
+				enc.pending[channel] = append(enc.pending[channel], samples[n])
+				n++
+			}
+		}
+
+		if len(enc.pending[0]) == blockSize {
+			if err := enc.flushPending(); err != nil {
+				return n, err
+			}
+		}
+	}
+
+	return n, nil
+}
+
+// WriteSamplesPlanar buffers the given audio samples, stored separately for
+// each channel, and encodes a frame to the output stream for each
+// complete block of samples buffered. Every channel must hold the
+// same number of samples. Any final partial block is encoded by Close.
+//
+// It returns the number of samples per channel consumed; len(samples[0]) if err is nil.
+// Frames are written as described by WriteSamples.
+func (enc *Encoder) WriteSamplesPlanar(samples [][]int32) (n int, err error) {
+	if enc.err != nil {
+		return 0, enc.err
+	}
+
+	nchannels := int(enc.Info.NChannels)
+	if len(samples) != nchannels {
+		return 0, fmt.Errorf("channel count mismatch; expected %d, got %d", nchannels, len(samples))
+	}
+
+	for i, channel := range samples {
+		if len(channel) != len(samples[0]) {
+			return 0, fmt.Errorf("invalid number of samples in channel %d; expected %d, got %d", i, len(samples[0]), len(channel))
+		}
+	}
+
+	enc.initPending()
+	blockSize := cap(enc.pending[0])
+	for n < len(samples[0]) {
+		m := blockSize - len(enc.pending[0])
+		if rest := len(samples[0]) - n; m > rest {
+			m = rest
+		}
+
+		for channel := range enc.pending {
+			enc.pending[channel] = append(enc.pending[channel], samples[channel][n:n+m]...)
+		}
+		n += m
+
+		if len(enc.pending[0]) == blockSize {
+			if err := enc.flushPending(); err != nil {
+				return n, err
+			}
+		}
+	}
+
+	return n, nil
+}
+
+// initPending allocates the buffers of pending audio samples
+// used by WriteSamples and WriteSamplesPlanar, if not yet allocated.
+func (enc *Encoder) initPending() {
+	if enc.pending != nil {
+		return
+	}
+
+	blockSize := defaultBlockSize
+	if enc.opts != nil {
+		blockSize = int(enc.opts.BlockSize)
+	} else if enc.Info.BlockSizeMax >= 16 {
+		blockSize = int(enc.Info.BlockSizeMax)
+	}
+
+	enc.pending = make([][]int32, enc.Info.NChannels)
+	for channel := range enc.pending {
+		enc.pending[channel] = make([]int32, 0, blockSize)
+	}
+}
+
+// flushPending encodes the buffered audio samples to the output stream,
+// as a single frame or as frames of variable block size.
+func (enc *Encoder) flushPending() error {
+	if len(enc.pending) == 0 || len(enc.pending[0]) == 0 {
+		return nil
+	}
+
+	n := len(enc.pending[0])
+	sizes := []int{n}
+	variable := enc.opts != nil && enc.opts.VariableBlockSize
+	var err error
+	if variable {
+		if sizes, _, err = enc.splitBlock(0, n, 0); err != nil {
+			return err
+		}
+	}
+
+	offset := 0
+	for _, size := range sizes {
+		if err = enc.WriteFrame(enc.pendingFrame(offset, size, !variable)); err != nil {
+			break
+		}
+		offset += size
+	}
+
+	// the buffers are reused once the frames have been encoded
+	for channel := range enc.pending {
+		enc.pending[channel] = enc.pending[channel][:0]
+	}
+
+	return err
+}
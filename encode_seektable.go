@@ -0,0 +1,90 @@
+package flac
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/pchchv/flac/meta"
+)
+
+// maxSeekPoints is the highest number of seek points of a SeekTable,
+// whose length in bytes is stored using 24 bits; each seek point is 18 bytes.
+const maxSeekPoints = (1<<24 - 1) / 18
+
+// seekInterval returns the interval in samples between
+// the seek points reserved by the encoder; 0 if disabled.
+func (opts *EncoderOptions) seekInterval(sampleRate uint32) uint64 {
+	if opts.SeekIntervalSamples != 0 {
+		return opts.SeekIntervalSamples
+	}
+
+	return uint64(opts.SeekInterval.Seconds() * float64(sampleRate))
+}
+
+// reserveSeekTable prepends a SeekTable metadata block of placeholder
+// seek points to the given metadata blocks, with one seek point
+// for every interval samples of the stream, or the number of
+// seek points of the encoder options if set.
+func (enc *Encoder) reserveSeekTable(interval uint64, blocks []*meta.Block) ([]*meta.Block, error) {
+	for _, block := range blocks {
+		if block.Type == meta.TypeSeekTable {
+			return nil, errors.New("unable to reserve seek table; metadata blocks already contain a seek table")
+		}
+	}
+
+	npoints := uint64(enc.opts.SeekPoints)
+	if npoints == 0 {
+		nsamples := enc.Info.NSamples
+		if nsamples == 0 {
+			return nil, errors.New("unable to reserve seek table; unknown number of samples in StreamInfo and number of seek points unset")
+		}
+
+		npoints = (nsamples + interval - 1) / interval
+		if npoints > maxSeekPoints {
+			return nil, fmt.Errorf("unable to reserve seek table; too many seek points (%d), expected at most %d", npoints, maxSeekPoints)
+		}
+	}
+
+	// target sample numbers of the seek points,
+	// which are filled in as frames are written
+	enc.seekTargets = make([]uint64, npoints)
+	table := &meta.SeekTable{Points: make([]meta.SeekPoint, npoints)}
+	for i := range table.Points {
+		enc.seekTargets[i] = uint64(i) * interval
+		table.Points[i].SampleNum = meta.PlaceholderPoint
+	}
+	enc.seekTable = table
+
+	block := &meta.Block{
+		Header: meta.Header{
+			Type:   meta.TypeSeekTable,
+			Length: int64(npoints) * 18,
+		},
+		Body: table,
+	}
+
+	return append([]*meta.Block{block}, blocks...), nil
+}
+
+// addSeekPoints fills in the reserved seek points targeting
+// samples of the frame written at the given offset, starting at
+// the given sample number and holding nsamples samples per channel.
+// Seek points of targets within the same frame are only filled in once,
+// leaving the remaining seek points as placeholders at the end of the seek table.
+func (enc *Encoder) addSeekPoints(sampleNum, offset uint64, nsamples int) {
+	end := sampleNum + uint64(nsamples)
+	for len(enc.seekTargets) > 0 && enc.seekTargets[0] < end {
+		enc.seekTargets = enc.seekTargets[1:]
+		points := enc.seekTable.Points
+		if enc.nseekPoints > 0 && points[enc.nseekPoints-1].SampleNum == sampleNum {
+			continue
+		}
+
+		points[enc.nseekPoints] = meta.SeekPoint{
+			SampleNum: sampleNum,
+			Offset:    offset,
+			NSamples:  uint16(nsamples),
+		}
+		enc.nseekPoints++
+	}
+}
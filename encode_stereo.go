@@ -0,0 +1,312 @@
+package flac
+
+import (
+	mathbits "math/bits"
+
+	"github.com/icza/bitio"
+	"github.com/pchchv/flac/frame"
+)
+
+// analyzeStereo determines the channel assignment of the given stereo frame and
+// the prediction method and residual coding parameters of its subframes.
+// Frames with independent left and right channels are decorrelated
+// according to the stereo mode of the encoder.
+// It returns the size in bits of the encoded subframes.
+func (enc *Encoder) analyzeStereo(f *frame.Frame) (uint64, error) {
+	bps := uint(f.BitsPerSample)
+	left := f.Subframes[0].Samples
+	right := f.Subframes[1].Samples
+	mid := make([]int32, len(left))
+	side := make([]int32, len(left))
+	var wide []int64
+	if bps == 32 {
+		wide = make([]int64, len(left))
+	}
+
+	for i := range left {
+		// inter-channel decorrelation:
+		//	mid = (left + right)/2
+		//	side = left - right
+		l, r := int64(left[i]), int64(right[i])
+		mid[i] = int32((l + r) >> 1)
+		side[i] = int32(l - r)
+		if wide != nil {
+			wide[i] = l - r
+		}
+	}
+
+	// the side channel of 32-bit audio samples requires 33 bits per sample,
+	// unless its audio samples happen to fit in 32 bits
+	if wide != nil && fitsInt32(wide, 0) {
+		wide = nil
+	}
+
+	if f.Channels == frame.ChannelsLR {
+		switch enc.opts.StereoMode {
+		case StereoAdaptive:
+			f.Channels = estimateChannels(left, right, mid, side, wide, bps)
+		case StereoExhaustive:
+			return enc.analyzeStereoExhaustive(f, left, right, mid, side, wide)
+		}
+	}
+
+	// side channel requires an extra bit per sample
+	samples := [2][]int32{left, right}
+	sideChannel := -1
+	switch f.Channels {
+	case frame.ChannelsLeftSide:
+		sideChannel = 1
+	case frame.ChannelsSideRight:
+		sideChannel = 0
+	case frame.ChannelsMidSide:
+		samples[0] = mid
+		sideChannel = 1
+	}
+
+	var total uint64
+	for i := range samples {
+		var hdr frame.SubHeader
+		var nbits uint64
+		var err error
+		if i == sideChannel {
+			hdr, nbits, err = enc.analyzeSide(side, wide, bps+1)
+		} else {
+			hdr, nbits, err = enc.analyze(samples[i], bps)
+		}
+
+		if err != nil {
+			return 0, err
+		}
+		f.Subframes[i].SubHeader = hdr
+		total += nbits
+	}
+
+	return total, nil
+}
+
+// analyzeStereoExhaustive analyzes the left, right, mid and side channels of
+// the given stereo frame, and uses the channel assignment which
+// encodes the audio samples using the fewest bits.
+// The side channel is held by wide instead of side if it exceeds 32 bits per sample.
+func (enc *Encoder) analyzeStereoExhaustive(f *frame.Frame, left, right, mid, side []int32, wide []int64) (uint64, error) {
+	bps := uint(f.BitsPerSample)
+	var hdrs [4]frame.SubHeader
+	var nbits [4]uint64
+	for i, samples := range [][]int32{left, right, mid} {
+		var err error
+		if hdrs[i], nbits[i], err = enc.analyze(samples, bps); err != nil {
+			return 0, err
+		}
+	}
+
+	// side channel requires an extra bit per sample
+	var err error
+	if hdrs[3], nbits[3], err = enc.analyzeSide(side, wide, bps+1); err != nil {
+		return 0, err
+	}
+
+	const l, r, m, s = 0, 1, 2, 3
+	pairs := []struct {
+		channels frame.Channels
+		a, b     int
+	}{
+		{frame.ChannelsLR, l, r},
+		{frame.ChannelsLeftSide, l, s},
+		{frame.ChannelsSideRight, s, r},
+		{frame.ChannelsMidSide, m, s},
+	}
+
+	best := pairs[0]
+	for _, pair := range pairs[1:] {
+		if nbits[pair.a]+nbits[pair.b] < nbits[best.a]+nbits[best.b] {
+			best = pair
+		}
+	}
+
+	f.Channels = best.channels
+	f.Subframes[0].SubHeader = hdrs[best.a]
+	f.Subframes[1].SubHeader = hdrs[best.b]
+	return nbits[best.a] + nbits[best.b], nil
+}
+
+// analyzeSide determines the prediction method and residual coding parameters
+// which encode the side channel using the fewest bits. The side channel is held
+// by wide if it exceeds 32 bits per sample, and by side otherwise.
+// Audio samples exceeding 32 bits per sample, even once wasted bits-per-sample
+// are removed, are only encoded using verbatim or fixed prediction.
+func (enc *Encoder) analyzeSide(side []int32, wide []int64, bps uint) (frame.SubHeader, uint64, error) {
+	if wide == nil {
+		return enc.analyze(side, bps)
+	}
+
+	if isConstantWide(wide) {
+		return frame.SubHeader{Pred: frame.PredConstant}, uint64(bps), nil
+	}
+
+	// analyze the audio samples with wasted bits-per-sample removed,
+	// if the remaining bits fit in 32 bits
+	var x int64
+	for _, sample := range wide {
+		x |= sample
+	}
+
+	if wasted := uint(mathbits.TrailingZeros64(uint64(x))); wasted > 0 && fitsInt32(wide, wasted) {
+		shifted := make([]int32, len(wide))
+		for i, sample := range wide {
+			shifted[i] = int32(sample >> wasted)
+		}
+
+		hdr, nbits, err := enc.analyze(shifted, bps-wasted)
+		hdr.Wasted = wasted
+		return hdr, nbits + uint64(wasted), err
+	}
+
+	// verbatim prediction is used as fallback
+	best := frame.SubHeader{Pred: frame.PredVerbatim}
+	bestBits := uint64(len(wide)) * uint64(bps)
+
+	// fixed prediction; skipped if the residuals overflow 32 bits
+	for order := 0; order <= maxFixedOrder && order < len(wide); order++ {
+		residuals, err := wideResiduals(wide, frame.FixedCoeffs[order], 0)
+		if err != nil {
+			continue
+		}
+
+		hdr := frame.SubHeader{Pred: frame.PredFixed, Order: order}
+		if nbits := uint64(order)*uint64(bps) + enc.riceCoding(&hdr, residuals); nbits < bestBits {
+			best, bestBits = hdr, nbits
+		}
+	}
+
+	// 1 bit wasted bits-per-sample flag
+	return best, bestBits + 1, nil
+}
+
+// hasWideSide reports whether the frame with the given header has a side
+// channel of more than 32 bits per sample.
+func hasWideSide(hdr frame.Header) bool {
+	if hdr.BitsPerSample != 32 {
+		return false
+	}
+
+	switch hdr.Channels {
+	case frame.ChannelsLeftSide, frame.ChannelsSideRight, frame.ChannelsMidSide:
+		return true
+	default:
+		return false
+	}
+}
+
+// encodeWideStereo encodes the subframes of the given stereo frame of
+// 32-bit audio samples using inter-channel decorrelation, writing to bw.
+// The side channel requires 33 bits per sample, and is decorrelated
+// using 64-bit arithmetic.
+func encodeWideStereo(bw *bitio.Writer, f *frame.Frame) error {
+	bps := uint(f.BitsPerSample)
+	left := f.Subframes[0].Samples
+	right := f.Subframes[1].Samples
+	side := make([]int64, len(left))
+	for i := range side {
+		side[i] = int64(left[i]) - int64(right[i])
+	}
+
+	switch f.Channels {
+	case frame.ChannelsLeftSide:
+		if err := encodeSubframe(bw, f.Header, f.Subframes[0], bps); err != nil {
+			return err
+		}
+		return encodeWideSubframe(bw, f.Subframes[1].SubHeader, side, bps+1)
+	case frame.ChannelsSideRight:
+		if err := encodeWideSubframe(bw, f.Subframes[0].SubHeader, side, bps+1); err != nil {
+			return err
+		}
+		return encodeSubframe(bw, f.Header, f.Subframes[1], bps)
+	default:
+		mid := &frame.Subframe{
+			SubHeader: f.Subframes[0].SubHeader,
+			Samples:   make([]int32, len(left)),
+			NSamples:  len(left),
+		}
+		for i := range left {
+			mid.Samples[i] = int32((int64(left[i]) + int64(right[i])) >> 1)
+		}
+
+		if err := encodeSubframe(bw, f.Header, mid, bps); err != nil {
+			return err
+		}
+		return encodeWideSubframe(bw, f.Subframes[1].SubHeader, side, bps+1)
+	}
+}
+
+// fitsInt32 reports whether the given audio samples, shifted right by
+// the given number of bits, fit in 32 bits.
+func fitsInt32(samples []int64, shift uint) bool {
+	for _, sample := range samples {
+		if x := sample >> shift; x != int64(int32(x)) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// isConstantWide reports whether all audio samples have the same value.
+func isConstantWide(samples []int64) bool {
+	for _, sample := range samples {
+		if sample != samples[0] {
+			return false
+		}
+	}
+
+	return len(samples) > 0
+}
+
+// estimateChannels returns the channel assignment whose inter-channel
+// decorrelation is estimated to encode the audio samples using the fewest bits.
+// The side channel is held by wide instead of side if it exceeds 32 bits per
+// sample, in which case it is estimated to be stored verbatim.
+func estimateChannels(left, right, mid, side []int32, wide []int64, bps uint) frame.Channels {
+	l := estimateBits(left)
+	r := estimateBits(right)
+	m := estimateBits(mid)
+	s := estimateBits(side)
+	if wide != nil {
+		s = uint64(len(wide)) * uint64(bps+1)
+	}
+
+	best, bestBits := frame.ChannelsLR, l+r
+	if nbits := l + s; nbits < bestBits {
+		best, bestBits = frame.ChannelsLeftSide, nbits
+	}
+
+	if nbits := s + r; nbits < bestBits {
+		best, bestBits = frame.ChannelsSideRight, nbits
+	}
+
+	if nbits := m + s; nbits < bestBits {
+		best = frame.ChannelsMidSide
+	}
+
+	return best
+}
+
+// estimateBits returns the estimated number of bits used to encode
+// the residuals of the given audio samples,
+// using the fixed prediction order of the lowest residual energy
+// and a single Rice partition.
+func estimateBits(samples []int32) uint64 {
+	n := uint64(len(samples))
+	if n == 0 {
+		return 0
+	}
+
+	// the sum of folded residuals is about twice the sum of absolute residuals
+	_, sum := bestFixedOrder(samples)
+	sum *= 2
+	var k uint
+	for mean := sum / n; mean > 1 && k < maxRice2Param; mean >>= 1 {
+		k++
+	}
+
+	return n*uint64(k+1) + sum>>k
+}
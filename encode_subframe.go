@@ -251,24 +251,10 @@ func encodeFIRSamples(bw *bitio.Writer, subframe *frame.Subframe, bps uint) erro
 		}
 	}
 
-	// 4 bits: (coefficients' precision in bits) - 1
-	if err := bw.WriteBits(uint64(subframe.CoeffPrec-1), 4); err != nil {
-		return err
-	}
-
-	// 5 bits: predictor coefficient shift needed in bits
-	if err := bw.WriteBits(uint64(subframe.CoeffShift), 5); err != nil {
+	if err := encodeFIRCoeffs(bw, subframe.SubHeader); err != nil {
 		return err
 	}
 
-	// encode coefficients
-	for _, coeff := range subframe.Coeffs {
-		// (prec) bits: Predictor coefficient
-		if err := bw.WriteBits(uint64(coeff), uint8(subframe.CoeffPrec)); err != nil {
-			return err
-		}
-	}
-
 	// compute residuals (signal errors of the prediction)
 	// between audio samples and LPC predicted audio samples.
 	residuals, err := getLPCResiduals(subframe, subframe.Coeffs, subframe.CoeffShift)
@@ -284,6 +270,30 @@ func encodeFIRSamples(bw *bitio.Writer, subframe *frame.Subframe, bps uint) erro
 	return nil
 }
 
+// encodeFIRCoeffs stores the precision, shift and quantized
+// predictor coefficients of the given subframe header, writing to bw.
+func encodeFIRCoeffs(bw *bitio.Writer, subHdr frame.SubHeader) error {
+	// 4 bits: (coefficients' precision in bits) - 1
+	if err := bw.WriteBits(uint64(subHdr.CoeffPrec-1), 4); err != nil {
+		return err
+	}
+
+	// 5 bits: predictor coefficient shift needed in bits
+	if err := bw.WriteBits(uint64(subHdr.CoeffShift), 5); err != nil {
+		return err
+	}
+
+	// encode coefficients
+	for _, coeff := range subHdr.Coeffs {
+		// (prec) bits: Predictor coefficient
+		if err := bw.WriteBits(uint64(coeff), uint8(subHdr.CoeffPrec)); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
 // encodeSubframe encodes the given subframe, writing to bw.
 func encodeSubframe(bw *bitio.Writer, hdr frame.Header, subframe *frame.Subframe, bps uint) error {
 	// encode subframe header
@@ -357,8 +367,105 @@ func getLPCResiduals(subframe *frame.Subframe, coeffs []int32, shift int32) ([]i
 		for j, c := range coeffs {
 			sample += int64(c) * int64(subframe.Samples[i-j-1])
 		}
-		residual := subframe.Samples[i] - int32(sample>>uint(shift))
-		residuals = append(residuals, residual)
+		// residuals of 32-bit audio samples may not fit in 32 bits
+		residual := int64(subframe.Samples[i]) - sample>>uint(shift)
+		if residual != int64(int32(residual)) {
+			return nil, fmt.Errorf("getLPCResiduals: residual %d of sample %d overflows 32-bit integer", residual, i)
+		}
+		residuals = append(residuals, int32(residual))
+	}
+
+	return residuals, nil
+}
+
+// encodeWideSubframe encodes the audio samples of a side channel of more than
+// 32 bits per sample, as used by the inter-channel decorrelation of 32-bit
+// audio samples, using the given subframe header, writing to bw.
+func encodeWideSubframe(bw *bitio.Writer, subHdr frame.SubHeader, samples []int64, bps uint) error {
+	if err := encodeSubframeHeader(bw, subHdr); err != nil {
+		return err
+	}
+
+	// right shift to account for wasted bits-per-sample
+	bps -= subHdr.Wasted
+	if subHdr.Wasted > 0 {
+		shifted := make([]int64, len(samples))
+		for i, sample := range samples {
+			shifted[i] = sample >> subHdr.Wasted
+		}
+		samples = shifted
+	}
+
+	// unencoded constant value, verbatim samples or warm-up samples
+	var n int
+	switch subHdr.Pred {
+	case frame.PredConstant:
+		n = 1
+	case frame.PredVerbatim:
+		n = len(samples)
+	case frame.PredFixed, frame.PredFIR:
+		n = subHdr.Order
+	default:
+		return fmt.Errorf("support for prediction method %v not yet implemented", subHdr.Pred)
+	}
+
+	if n > len(samples) {
+		return fmt.Errorf("prediction order %d exceeds sample count %d", n, len(samples))
+	}
+
+	if subHdr.Pred == frame.PredConstant {
+		for _, sample := range samples[1:] {
+			if sample != samples[0] {
+				return fmt.Errorf("constant sample mismatch; expected %v, got %v", samples[0], sample)
+			}
+		}
+	}
+
+	for _, sample := range samples[:n] {
+		if err := bw.WriteBits(uint64(sample), uint8(bps)); err != nil {
+			return err
+		}
+	}
+
+	coeffs, shift := frame.FixedCoeffs[subHdr.Order], int32(0)
+	switch subHdr.Pred {
+	case frame.PredConstant, frame.PredVerbatim:
+		return nil
+	case frame.PredFIR:
+		if err := encodeFIRCoeffs(bw, subHdr); err != nil {
+			return err
+		}
+		coeffs, shift = subHdr.Coeffs, subHdr.CoeffShift
+	}
+
+	residuals, err := wideResiduals(samples, coeffs, shift)
+	if err != nil {
+		return err
+	}
+
+	subframe := &frame.Subframe{SubHeader: subHdr, NSamples: len(samples)}
+	return encodeResiduals(bw, subframe, residuals)
+}
+
+// wideResiduals returns the residuals of the given audio samples of more than
+// 32 bits per sample, predicted using the given coefficients and shift.
+func wideResiduals(samples []int64, coeffs []int32, shift int32) ([]int32, error) {
+	if shift < 0 {
+		return nil, fmt.Errorf("wideResiduals: invalid negative shift")
+	}
+
+	residuals := make([]int32, 0, len(samples)-len(coeffs))
+	for i := len(coeffs); i < len(samples); i++ {
+		var sample int64
+		for j, c := range coeffs {
+			sample += int64(c) * samples[i-j-1]
+		}
+
+		residual := samples[i] - sample>>uint(shift)
+		if residual != int64(int32(residual)) {
+			return nil, fmt.Errorf("wideResiduals: residual %d of sample %d overflows 32-bit integer", residual, i)
+		}
+		residuals = append(residuals, int32(residual))
 	}
 
 	return residuals, nil
@@ -0,0 +1,123 @@
+package flac
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/pchchv/flac/frame"
+	"github.com/pchchv/flac/meta"
+)
+
+const (
+	// Limits of the FLAC streamable subset:
+
+	// subsetMaxBlockSize is the largest block size of subset streams.
+	subsetMaxBlockSize = 16384
+	// subsetMaxBlockSize48kHz is the largest block size of
+	// subset streams with a sample rate of at most 48 kHz.
+	subsetMaxBlockSize48kHz = 4608
+	// subsetMaxLPCOrder48kHz is the highest FIR prediction order of
+	// subset streams with a sample rate of at most 48 kHz.
+	subsetMaxLPCOrder48kHz = 12
+	// subsetMaxPartOrder is the highest Rice partition order of subset streams.
+	subsetMaxPartOrder = 8
+)
+
+// ErrNotSubset is wrapped by the errors of an encoder in subset mode
+// when the stream or a frame is outside of the FLAC streamable subset.
+var ErrNotSubset = errors.New("outside of streamable subset")
+
+// checkSubset reports whether the given StreamInfo metadata block
+// and encoder options are within the FLAC streamable subset.
+func checkSubset(info *meta.StreamInfo, opts *EncoderOptions) error {
+	if !isHeaderSampleRate(info.SampleRate) {
+		return fmt.Errorf("%w; sample rate %d Hz not encodable in frame header", ErrNotSubset, info.SampleRate)
+	}
+
+	if !isHeaderBitsPerSample(info.BitsPerSample) {
+		return fmt.Errorf("%w; sample size %d not encodable in frame header", ErrNotSubset, info.BitsPerSample)
+	}
+
+	maxBlockSize := subsetMaxBlockSize
+	if info.SampleRate <= 48000 {
+		maxBlockSize = subsetMaxBlockSize48kHz
+		if opts.MaxLPCOrder > subsetMaxLPCOrder48kHz {
+			return fmt.Errorf("%w; LPC order %d exceeds %d at sample rate %d Hz", ErrNotSubset, opts.MaxLPCOrder, subsetMaxLPCOrder48kHz, info.SampleRate)
+		}
+	}
+
+	for _, blockSize := range []uint16{opts.BlockSize, info.BlockSizeMax} {
+		if int(blockSize) > maxBlockSize {
+			return fmt.Errorf("%w; block size %d exceeds %d at sample rate %d Hz", ErrNotSubset, blockSize, maxBlockSize, info.SampleRate)
+		}
+	}
+
+	if opts.MaxPartOrder > subsetMaxPartOrder {
+		return fmt.Errorf("%w; Rice partition order %d exceeds %d", ErrNotSubset, opts.MaxPartOrder, subsetMaxPartOrder)
+	}
+
+	return nil
+}
+
+// checkSubsetFrame reports whether the given header of frame number num
+// is within the FLAC streamable subset; it must not refer to
+// StreamInfo for the sample rate or sample size.
+func checkSubsetFrame(hdr frame.Header, num uint64) error {
+	if !isHeaderSampleRate(hdr.SampleRate) {
+		return fmt.Errorf("%w; frame %d sample rate %d Hz not encodable in frame header", ErrNotSubset, num, hdr.SampleRate)
+	}
+
+	if !isHeaderBitsPerSample(hdr.BitsPerSample) {
+		return fmt.Errorf("%w; frame %d sample size %d not encodable in frame header", ErrNotSubset, num, hdr.BitsPerSample)
+	}
+
+	maxBlockSize := subsetMaxBlockSize
+	if hdr.SampleRate <= 48000 {
+		maxBlockSize = subsetMaxBlockSize48kHz
+	}
+
+	if int(hdr.BlockSize) > maxBlockSize {
+		return fmt.Errorf("%w; frame %d block size %d exceeds %d at sample rate %d Hz", ErrNotSubset, num, hdr.BlockSize, maxBlockSize, hdr.SampleRate)
+	}
+
+	return nil
+}
+
+// checkSubsetSubframes reports whether the subframe headers of the given frame
+// of frame number num are within the FLAC streamable subset.
+func checkSubsetSubframes(f *frame.Frame, num uint64) error {
+	for channel, subframe := range f.Subframes {
+		if subframe.Pred == frame.PredFIR && f.SampleRate <= 48000 && subframe.Order > subsetMaxLPCOrder48kHz {
+			return fmt.Errorf("%w; frame %d channel %d LPC order %d exceeds %d at sample rate %d Hz", ErrNotSubset, num, channel, subframe.Order, subsetMaxLPCOrder48kHz, f.SampleRate)
+		}
+
+		// Rice-coding parameters are only used by fixed and FIR linear prediction
+		if subframe.Pred != frame.PredFixed && subframe.Pred != frame.PredFIR || subframe.RiceSubframe == nil {
+			continue
+		}
+
+		if partOrder := subframe.RiceSubframe.PartOrder; partOrder > subsetMaxPartOrder {
+			return fmt.Errorf("%w; frame %d channel %d Rice partition order %d exceeds %d", ErrNotSubset, num, channel, partOrder, subsetMaxPartOrder)
+		}
+	}
+
+	return nil
+}
+
+// isHeaderSampleRate reports whether the given sample rate
+// is encodable in frame headers; either by a dedicated code,
+// in kHz, in Hz or in tens of Hz.
+func isHeaderSampleRate(sampleRate uint32) bool {
+	return sampleRate != 0 && (sampleRate <= 65535 || (sampleRate <= 655350 && sampleRate%10 == 0))
+}
+
+// isHeaderBitsPerSample reports whether the given sample size
+// is encodable in frame headers of subset streams.
+func isHeaderBitsPerSample(bps uint8) bool {
+	switch bps {
+	case 8, 12, 16, 20, 24, 32:
+		return true
+	}
+
+	return false
+}
@@ -2,11 +2,21 @@ package flac_test
 
 import (
 	"bytes"
+	"crypto/md5"
+	"encoding/binary"
+	"errors"
+	"hash"
 	"io"
+	"math"
 	"os"
+	"path/filepath"
+	"reflect"
+	"runtime"
 	"testing"
+	"time"
 
 	"github.com/pchchv/flac"
+	"github.com/pchchv/flac/frame"
 	"github.com/pchchv/flac/meta"
 )
 
@@ -210,3 +220,1821 @@ func TestEncodeComment(t *testing.T) {
 		}
 	}
 }
+
+func TestEncodePredictionAnalysis(t *testing.T) {
+	paths := []string{
+		"meta/testdata/silence.flac",
+		"testdata/19875.flac",
+		"testdata/44127.flac",
+		"testdata/59996.flac",
+		"testdata/80574.flac",
+		"testdata/172960.flac",
+		"testdata/189983.flac",
+		"testdata/191885.flac",
+		"testdata/212768.flac",
+		"testdata/220014.flac",
+		"testdata/243749.flac",
+		"testdata/256529.flac",
+		"testdata/257344.flac",
+		"testdata/8297-275156-0011.flac",
+		"testdata/love.flac",
+	}
+
+	for _, path := range paths {
+		t.Run(path, func(t *testing.T) {
+			// decode source file
+			stream, err := flac.Open(path)
+			if err != nil {
+				t.Fatalf("%q: unable to open FLAC file; %v", path, err)
+			}
+			defer stream.Close()
+
+			// open encoder for FLAC stream
+			out := new(bytes.Buffer)
+			enc, err := flac.NewEncoder(out, stream.Info)
+			if err != nil {
+				t.Fatalf("%q: unable to create encoder for FLAC stream; %v", path, err)
+			}
+			enc.EnablePredictionAnalysis(true)
+
+			// encode audio samples, providing only the samples of each frame
+			var want [][]int32
+			for {
+				f, err := stream.ParseNext()
+				if err != nil {
+					if err == io.EOF {
+						break
+					}
+					t.Fatalf("%q: unable to parse audio frame of FLAC stream; %v", path, err)
+				}
+
+				raw := &frame.Frame{Header: frame.Header{HasFixedBlockSize: f.HasFixedBlockSize}}
+				for _, subframe := range f.Subframes {
+					samples := append([]int32(nil), subframe.Samples...)
+					raw.Subframes = append(raw.Subframes, &frame.Subframe{Samples: samples})
+					want = append(want, subframe.Samples)
+				}
+
+				if err := enc.WriteFrame(raw); err != nil {
+					t.Fatalf("%q: unable to encode audio frame of FLAC stream; %v", path, err)
+				}
+			}
+
+			// close encoder and flush pending writes
+			if err := enc.Close(); err != nil {
+				t.Fatalf("%q: unable to close encoder for FLAC stream; %v", path, err)
+			}
+
+			// compare source and decoded destination audio samples
+			got := decodeSamples(t, out)
+			if len(got) != len(want) {
+				t.Fatalf("%q: subframe count mismatch; expected %d, got %d", path, len(want), len(got))
+			}
+
+			for i := range want {
+				if !reflect.DeepEqual(got[i], want[i]) {
+					t.Fatalf("%q: sample mismatch in subframe %d", path, i)
+				}
+			}
+		})
+	}
+}
+
+// decodeSamples decodes the FLAC stream of r and returns the audio samples of each subframe.
+func decodeSamples(t *testing.T, r io.Reader) [][]int32 {
+	stream, err := flac.New(r)
+	if err != nil {
+		t.Fatalf("unable to parse output FLAC stream; %v", err)
+	}
+	defer stream.Close()
+
+	var subframes [][]int32
+	for {
+		f, err := stream.ParseNext()
+		if err != nil {
+			if err == io.EOF {
+				break
+			}
+			t.Fatalf("unable to parse audio frame of output FLAC stream; %v", err)
+		}
+
+		for _, subframe := range f.Subframes {
+			subframes = append(subframes, subframe.Samples)
+		}
+	}
+
+	return subframes
+}
+
+func TestEncodeRiceCoding(t *testing.T) {
+	// 24-bit mono signal with a silent first half and a loud noisy second half,
+	// which requires both escaped partitions and 5-bit Rice parameters
+	const blockSize = 4096
+	info := &meta.StreamInfo{
+		BlockSizeMin:  blockSize,
+		BlockSizeMax:  blockSize,
+		SampleRate:    48000,
+		NChannels:     1,
+		BitsPerSample: 24,
+	}
+
+	samples := make([]int32, blockSize)
+	seed := uint32(1)
+	for i := blockSize / 2; i < blockSize; i++ {
+		var x int32
+		for j := 0; j < 4; j++ {
+			seed = seed*1664525 + 1013904223
+			x += int32(seed) >> 13
+		}
+		samples[i] = x
+	}
+
+	out := new(bytes.Buffer)
+	enc, err := flac.NewEncoderOptions(out, info, flac.LevelOptions(flac.DefaultLevel))
+	if err != nil {
+		t.Fatalf("unable to create encoder for FLAC stream; %v", err)
+	}
+
+	f := &frame.Frame{
+		Header:    frame.Header{HasFixedBlockSize: true},
+		Subframes: []*frame.Subframe{{Samples: append([]int32(nil), samples...)}},
+	}
+	if err := enc.WriteFrame(f); err != nil {
+		t.Fatalf("unable to encode audio frame; %v", err)
+	}
+
+	if err := enc.Close(); err != nil {
+		t.Fatalf("unable to close encoder for FLAC stream; %v", err)
+	}
+
+	stream, err := flac.New(out)
+	if err != nil {
+		t.Fatalf("unable to parse output FLAC stream; %v", err)
+	}
+
+	got, err := stream.ParseNext()
+	if err != nil {
+		t.Fatalf("unable to parse audio frame of output FLAC stream; %v", err)
+	}
+
+	subframe := got.Subframes[0]
+	if !reflect.DeepEqual(subframe.Samples, samples) {
+		t.Fatalf("sample mismatch")
+	}
+
+	if subframe.ResidualCodingMethod != frame.ResidualCodingMethodRice2 {
+		t.Errorf("residual coding method mismatch; expected rice2, got %v", subframe.ResidualCodingMethod)
+	}
+
+	if subframe.RiceSubframe == nil || subframe.RiceSubframe.PartOrder == 0 {
+		t.Fatalf("expected partitioned Rice coding, got %+v", subframe.RiceSubframe)
+	}
+
+	escaped := false
+	for _, partition := range subframe.RiceSubframe.Partitions {
+		if partition.Param == 0x1F && partition.EscapedBitsPerSample == 0 {
+			escaped = true
+		}
+	}
+
+	if !escaped {
+		t.Errorf("expected escaped partitions of silence, got %+v", subframe.RiceSubframe.Partitions)
+	}
+}
+
+func TestEncodeStereoDecorrelation(t *testing.T) {
+	// highly correlated stereo signal; the right channel is
+	// the left channel with a small amount of noise added
+	const blockSize = 4096
+	info := &meta.StreamInfo{
+		BlockSizeMin:  blockSize,
+		BlockSizeMax:  blockSize,
+		SampleRate:    44100,
+		NChannels:     2,
+		BitsPerSample: 16,
+	}
+
+	left := make([]int32, blockSize)
+	right := make([]int32, blockSize)
+	seed := uint32(1)
+	for i := range left {
+		seed = seed*1664525 + 1013904223
+		left[i] = int32(20000 * math.Sin(float64(i)*2*math.Pi*0.0071) * math.Sin(float64(i)*2*math.Pi*0.031))
+		right[i] = left[i] + int32(seed)>>29
+	}
+
+	// the frame of the caller is left unchanged, whether encoded sequentially or concurrently
+	for _, workers := range []int{1, 4} {
+		opts := flac.LevelOptions(flac.DefaultLevel)
+		opts.Workers = workers
+		out := new(bytes.Buffer)
+		enc, err := flac.NewEncoderOptions(out, info, opts)
+		if err != nil {
+			t.Fatalf("workers %d: unable to create encoder for FLAC stream; %v", workers, err)
+		}
+
+		f := &frame.Frame{
+			Header: frame.Header{HasFixedBlockSize: true},
+			Subframes: []*frame.Subframe{
+				{Samples: append([]int32(nil), left...)},
+				{Samples: append([]int32(nil), right...)},
+			},
+		}
+		if err := enc.WriteFrame(f); err != nil {
+			t.Fatalf("workers %d: unable to encode audio frame; %v", workers, err)
+		}
+
+		if f.Channels != frame.ChannelsLR {
+			t.Errorf("workers %d: channel assignment of frame modified; expected %v, got %v", workers, frame.ChannelsLR, f.Channels)
+		}
+
+		for i, subframe := range f.Subframes {
+			if subframe.Pred != frame.PredConstant || subframe.Order != 0 {
+				t.Errorf("workers %d: subframe header %d of frame modified; got %+v", workers, i, subframe.SubHeader)
+			}
+		}
+
+		if err := enc.Close(); err != nil {
+			t.Fatalf("workers %d: unable to close encoder for FLAC stream; %v", workers, err)
+		}
+
+		stream, err := flac.New(bytes.NewReader(out.Bytes()))
+		if err != nil {
+			t.Fatalf("workers %d: unable to parse output FLAC stream; %v", workers, err)
+		}
+
+		g, err := stream.ParseNext()
+		if err != nil {
+			t.Fatalf("workers %d: unable to parse audio frame of output FLAC stream; %v", workers, err)
+		}
+
+		if g.Channels == frame.ChannelsLR {
+			t.Errorf("workers %d: expected inter-channel decorrelation of correlated channels", workers)
+		}
+
+		got := decodeSamples(t, out)
+		if !reflect.DeepEqual(got, [][]int32{left, right}) {
+			t.Fatalf("workers %d: sample mismatch", workers)
+		}
+	}
+}
+
+func TestEncodeWastedBits(t *testing.T) {
+	// 16-bit audio samples padded to a 24-bit container
+	stream, err := flac.Open("testdata/172960.flac")
+	if err != nil {
+		t.Fatalf("unable to open FLAC file; %v", err)
+	}
+	defer stream.Close()
+
+	info := *stream.Info
+	info.BitsPerSample = 24
+	out := new(bytes.Buffer)
+	enc, err := flac.NewEncoderOptions(out, &info, flac.LevelOptions(flac.DefaultLevel))
+	if err != nil {
+		t.Fatalf("unable to create encoder for FLAC stream; %v", err)
+	}
+
+	var want [][]int32
+	for {
+		f, err := stream.ParseNext()
+		if err != nil {
+			if err == io.EOF {
+				break
+			}
+			t.Fatalf("unable to parse audio frame of FLAC stream; %v", err)
+		}
+
+		raw := &frame.Frame{Header: frame.Header{HasFixedBlockSize: true}}
+		for _, subframe := range f.Subframes {
+			samples := make([]int32, len(subframe.Samples))
+			for i, sample := range subframe.Samples {
+				samples[i] = sample << 8
+			}
+			raw.Subframes = append(raw.Subframes, &frame.Subframe{Samples: samples})
+			want = append(want, append([]int32(nil), samples...))
+		}
+
+		if err := enc.WriteFrame(raw); err != nil {
+			t.Fatalf("unable to encode audio frame; %v", err)
+		}
+
+		for i, subframe := range raw.Subframes {
+			// the mid channel discards the least significant bit of left + right
+			want := uint(8)
+			if raw.Channels == frame.ChannelsMidSide && i == 0 {
+				want = 7
+			}
+			if subframe.Pred != frame.PredConstant && subframe.Wasted < want {
+				t.Errorf("frame %d, subframe %d: wasted bits-per-sample mismatch; expected >= %d, got %d", f.Num, i, want, subframe.Wasted)
+			}
+		}
+	}
+
+	if err := enc.Close(); err != nil {
+		t.Fatalf("unable to close encoder for FLAC stream; %v", err)
+	}
+
+	if got := decodeSamples(t, out); !reflect.DeepEqual(got, want) {
+		t.Fatalf("sample mismatch")
+	}
+}
+
+func TestEncodeLevels(t *testing.T) {
+	const path = "testdata/love.flac"
+	stream, err := flac.ParseFile(path)
+	if err != nil {
+		t.Fatalf("%q: unable to parse FLAC file; %v", path, err)
+	}
+	defer stream.Close()
+
+	var want [][]int32
+	for {
+		f, err := stream.ParseNext()
+		if err != nil {
+			if err == io.EOF {
+				break
+			}
+			t.Fatalf("%q: unable to parse audio frame of FLAC stream; %v", path, err)
+		}
+
+		for _, subframe := range f.Subframes {
+			want = append(want, subframe.Samples)
+		}
+	}
+
+	sizes := make([]int, flac.MaxLevel+1)
+	for level := range sizes {
+		out := new(bytes.Buffer)
+		enc, err := flac.NewEncoderOptions(out, stream.Info, flac.LevelOptions(level))
+		if err != nil {
+			t.Fatalf("level %d: unable to create encoder; %v", level, err)
+		}
+
+		nchannels := int(stream.Info.NChannels)
+		for i := 0; i < len(want); i += nchannels {
+			raw := &frame.Frame{Header: frame.Header{HasFixedBlockSize: true}}
+			for _, samples := range want[i : i+nchannels] {
+				raw.Subframes = append(raw.Subframes, &frame.Subframe{Samples: samples})
+			}
+
+			if err := enc.WriteFrame(raw); err != nil {
+				t.Fatalf("level %d: unable to encode audio frame; %v", level, err)
+			}
+		}
+
+		if err := enc.Close(); err != nil {
+			t.Fatalf("level %d: unable to close encoder; %v", level, err)
+		}
+
+		sizes[level] = out.Len()
+		if got := decodeSamples(t, out); !reflect.DeepEqual(got, want) {
+			t.Fatalf("level %d: sample mismatch", level)
+		}
+	}
+
+	if sizes[flac.MaxLevel] >= sizes[0] {
+		t.Errorf("level %d output not smaller than level 0; got %d and %d bytes", flac.MaxLevel, sizes[flac.MaxLevel], sizes[0])
+	}
+}
+
+func TestEncodeApodizations(t *testing.T) {
+	const path = "testdata/love.flac"
+	stream, err := flac.ParseFile(path)
+	if err != nil {
+		t.Fatalf("%q: unable to parse FLAC file; %v", path, err)
+	}
+	defer stream.Close()
+
+	var frames []*frame.Frame
+	var want [][]int32
+	for {
+		f, err := stream.ParseNext()
+		if err != nil {
+			if err == io.EOF {
+				break
+			}
+			t.Fatalf("%q: unable to parse audio frame of FLAC stream; %v", path, err)
+		}
+
+		frames = append(frames, f)
+		for _, subframe := range f.Subframes {
+			want = append(want, subframe.Samples)
+		}
+	}
+
+	apodizations := []flac.Apodization{
+		flac.Tukey(0.25),
+		flac.PartialTukey(3),
+		flac.PunchoutTukey(4),
+		{Window: flac.WindowGauss, P: 0.3},
+	}
+	for w := flac.WindowBartlett; w <= flac.WindowWelch; w++ {
+		if w != flac.WindowGauss {
+			apodizations = append(apodizations, flac.Apodization{Window: w})
+		}
+	}
+
+	for _, a := range apodizations {
+		opts := flac.LevelOptions(flac.DefaultLevel)
+		opts.Apodizations = []flac.Apodization{a}
+		out := new(bytes.Buffer)
+		enc, err := flac.NewEncoderOptions(out, stream.Info, opts)
+		if err != nil {
+			t.Fatalf("window %d: unable to create encoder; %v", a.Window, err)
+		}
+
+		for _, f := range frames {
+			raw := &frame.Frame{Header: frame.Header{HasFixedBlockSize: true}}
+			for _, subframe := range f.Subframes {
+				raw.Subframes = append(raw.Subframes, &frame.Subframe{Samples: subframe.Samples})
+			}
+
+			if err := enc.WriteFrame(raw); err != nil {
+				t.Fatalf("window %d: unable to encode audio frame; %v", a.Window, err)
+			}
+		}
+
+		if err := enc.Close(); err != nil {
+			t.Fatalf("window %d: unable to close encoder; %v", a.Window, err)
+		}
+
+		output, err := flac.New(bytes.NewReader(out.Bytes()))
+		if err != nil {
+			t.Fatalf("window %d: unable to parse output FLAC stream; %v", a.Window, err)
+		}
+
+		fir := false
+		for {
+			f, err := output.ParseNext()
+			if err != nil {
+				if err == io.EOF {
+					break
+				}
+				t.Fatalf("window %d: unable to parse audio frame of output FLAC stream; %v", a.Window, err)
+			}
+
+			for _, subframe := range f.Subframes {
+				fir = fir || subframe.Pred == frame.PredFIR
+			}
+		}
+
+		if !fir {
+			t.Errorf("window %d: no FIR linear prediction subframes", a.Window)
+		}
+
+		if got := decodeSamples(t, out); !reflect.DeepEqual(got, want) {
+			t.Fatalf("window %d: sample mismatch", a.Window)
+		}
+	}
+}
+
+func TestEncodeLPCOrder(t *testing.T) {
+	// 16-bit mono autoregressive signal of order 16, resonating at 8 frequencies
+	const blockSize = 4096
+	info := &meta.StreamInfo{
+		BlockSizeMin:  blockSize,
+		BlockSizeMax:  blockSize,
+		SampleRate:    44100,
+		NChannels:     1,
+		BitsPerSample: 16,
+	}
+
+	x := make([]float64, blockSize)
+	seed := uint32(1)
+	for i := range x {
+		seed = seed*1664525 + 1013904223
+		x[i] = float64(int32(seed) >> 24)
+	}
+
+	for k := 1; k <= 8; k++ {
+		// two-pole resonator with poles of radius 0.98
+		const r = 0.98
+		a1, a2 := 2*r*math.Cos(math.Pi*float64(k)/9), -r*r
+		var y1, y2 float64
+		for i, v := range x {
+			y := v + a1*y1 + a2*y2
+			x[i], y1, y2 = y, y, y1
+		}
+
+		var peak float64
+		for _, v := range x {
+			peak = math.Max(peak, math.Abs(v))
+		}
+		for i := range x {
+			x[i] *= 16384 / peak
+		}
+	}
+
+	samples := make([]int32, blockSize)
+	for i, v := range x {
+		samples[i] = int32(math.Round(v))
+	}
+
+	encode := func(maxOrder int, exhaustive bool) (int, *frame.Subframe) {
+		opts := flac.LevelOptions(flac.DefaultLevel)
+		opts.MaxLPCOrder = maxOrder
+		opts.ExhaustiveModelSearch = exhaustive
+		out := new(bytes.Buffer)
+		enc, err := flac.NewEncoderOptions(out, info, opts)
+		if err != nil {
+			t.Fatalf("order %d: unable to create encoder; %v", maxOrder, err)
+		}
+
+		f := &frame.Frame{
+			Header:    frame.Header{HasFixedBlockSize: true},
+			Subframes: []*frame.Subframe{{Samples: append([]int32(nil), samples...)}},
+		}
+		if err := enc.WriteFrame(f); err != nil {
+			t.Fatalf("order %d: unable to encode audio frame; %v", maxOrder, err)
+		}
+
+		if err := enc.Close(); err != nil {
+			t.Fatalf("order %d: unable to close encoder; %v", maxOrder, err)
+		}
+
+		size := out.Len()
+		stream, err := flac.New(bytes.NewReader(out.Bytes()))
+		if err != nil {
+			t.Fatalf("order %d: unable to parse output FLAC stream; %v", maxOrder, err)
+		}
+
+		g, err := stream.ParseNext()
+		if err != nil {
+			t.Fatalf("order %d: unable to parse audio frame of output FLAC stream; %v", maxOrder, err)
+		}
+
+		if got := decodeSamples(t, out); !reflect.DeepEqual(got, [][]int32{samples}) {
+			t.Fatalf("order %d: sample mismatch", maxOrder)
+		}
+
+		return size, g.Subframes[0]
+	}
+
+	fixedSize, fixed := encode(0, false)
+	if fixed.Pred != frame.PredFixed {
+		t.Fatalf("prediction method mismatch; expected fixed, got %v", fixed.Pred)
+	}
+
+	firSize, fir := encode(32, false)
+	if fir.Pred != frame.PredFIR || fir.Order <= 4 {
+		t.Fatalf("expected FIR linear prediction above order 4, got %v of order %d", fir.Pred, fir.Order)
+	}
+
+	if firSize >= fixedSize {
+		t.Errorf("FIR linear prediction of order %d not smaller than fixed prediction of order %d; %d >= %d bytes", fir.Order, fixed.Order, firSize, fixedSize)
+	}
+
+	if exhaustiveSize, _ := encode(32, true); exhaustiveSize > firSize {
+		t.Errorf("exhaustive model search larger than estimated; %d > %d bytes", exhaustiveSize, firSize)
+	}
+}
+
+func TestNewEncoderInvalidOptions(t *testing.T) {
+	info := &meta.StreamInfo{
+		SampleRate:    44100,
+		NChannels:     2,
+		BitsPerSample: 16,
+	}
+
+	tests := map[string]func(opts *flac.EncoderOptions){
+		"block size":      func(opts *flac.EncoderOptions) { opts.BlockSize = 8 },
+		"LPC order":       func(opts *flac.EncoderOptions) { opts.MaxLPCOrder = 33 },
+		"partition order": func(opts *flac.EncoderOptions) { opts.MinPartOrder = 7 },
+		"stereo mode":     func(opts *flac.EncoderOptions) { opts.StereoMode = 3 },
+		"window":          func(opts *flac.EncoderOptions) { opts.Apodizations = []flac.Apodization{{Window: 255}} },
+		"gauss":           func(opts *flac.EncoderOptions) { opts.Apodizations = []flac.Apodization{{Window: flac.WindowGauss}} },
+		"window count":    func(opts *flac.EncoderOptions) { opts.Apodizations = []flac.Apodization{flac.PartialTukey(33)} },
+		"seek interval": func(opts *flac.EncoderOptions) {
+			opts.SeekInterval = time.Microsecond
+			opts.SeekPoints = 10
+		},
+	}
+
+	for name, modify := range tests {
+		opts := flac.LevelOptions(flac.DefaultLevel)
+		modify(opts)
+		if _, err := flac.NewEncoderOptions(new(bytes.Buffer), info, opts); err == nil {
+			t.Errorf("invalid %s; expected error, got nil", name)
+		}
+	}
+}
+
+func TestNewEncoderInfo(t *testing.T) {
+	info := &meta.StreamInfo{
+		SampleRate:    44100,
+		NChannels:     1,
+		BitsPerSample: 16,
+	}
+
+	out, err := os.Create(filepath.Join(t.TempDir(), "out.flac"))
+	if err != nil {
+		t.Fatalf("unable to create output file; %v", err)
+	}
+
+	enc, err := flac.NewEncoder(out, info)
+	if err != nil {
+		t.Fatalf("unable to create encoder; %v", err)
+	}
+
+	samples := make([]int32, 1000)
+	f := &frame.Frame{
+		Header: frame.Header{
+			BlockSize:     uint16(len(samples)),
+			Channels:      frame.ChannelsMono,
+			BitsPerSample: 16,
+		},
+		Subframes: []*frame.Subframe{
+			{SubHeader: frame.SubHeader{Pred: frame.PredVerbatim}, Samples: samples, NSamples: len(samples)},
+		},
+	}
+	if err := enc.WriteFrame(f); err != nil {
+		t.Fatalf("unable to encode audio frame; %v", err)
+	}
+
+	if err := enc.Close(); err != nil {
+		t.Fatalf("unable to close encoder; %v", err)
+	}
+
+	// Close updates the StreamInfo of the caller
+	if info.NSamples != uint64(len(samples)) {
+		t.Errorf("sample count mismatch; expected %d, got %d", len(samples), info.NSamples)
+	}
+
+	if info.BlockSizeMax != uint16(len(samples)) {
+		t.Errorf("maximum block size mismatch; expected %d, got %d", len(samples), info.BlockSizeMax)
+	}
+}
+
+func TestEncodeWriteSamples(t *testing.T) {
+	const path = "testdata/love.flac"
+	stream, err := flac.ParseFile(path)
+	if err != nil {
+		t.Fatalf("%q: unable to parse FLAC file; %v", path, err)
+	}
+	defer stream.Close()
+
+	// decode audio samples, both interleaved and per channel
+	nchannels := int(stream.Info.NChannels)
+	var interleaved []int32
+	planar := make([][]int32, nchannels)
+	for {
+		f, err := stream.ParseNext()
+		if err != nil {
+			if err == io.EOF {
+				break
+			}
+			t.Fatalf("%q: unable to parse audio frame of FLAC stream; %v", path, err)
+		}
+
+		for i := 0; i < int(f.BlockSize); i++ {
+			for channel, subframe := range f.Subframes {
+				interleaved = append(interleaved, subframe.Samples[i])
+				planar[channel] = append(planar[channel], subframe.Samples[i])
+			}
+		}
+	}
+
+	// block size which leaves a final partial block
+	opts := flac.LevelOptions(flac.DefaultLevel)
+	opts.BlockSize = 1000
+	tests := []struct {
+		name  string
+		opts  *flac.EncoderOptions
+		write func(enc *flac.Encoder) error
+	}{
+		{
+			name: "interleaved",
+			opts: opts,
+			write: func(enc *flac.Encoder) error {
+				// odd chunks of inter-channel samples
+				for i := 0; i < len(interleaved); {
+					j := i + 777*nchannels
+					if j > len(interleaved) {
+						j = len(interleaved)
+					}
+					if _, err := enc.WriteSamples(interleaved[i:j]); err != nil {
+						return err
+					}
+					i = j
+				}
+				return nil
+			},
+		},
+		{
+			name: "planar verbatim",
+			opts: nil,
+			write: func(enc *flac.Encoder) error {
+				_, err := enc.WriteSamplesPlanar(planar)
+				return err
+			},
+		},
+	}
+
+	for _, test := range tests {
+		info := *stream.Info
+		info.BlockSizeMin, info.BlockSizeMax = 0, 0
+		info.NSamples = 0
+		info.MD5sum = [16]byte{}
+
+		outPath := filepath.Join(t.TempDir(), "out.flac")
+		out, err := os.Create(outPath)
+		if err != nil {
+			t.Fatalf("%s: unable to create output file; %v", test.name, err)
+		}
+
+		enc, err := flac.NewEncoderOptions(out, &info, test.opts)
+		if err != nil {
+			t.Fatalf("%s: unable to create encoder; %v", test.name, err)
+		}
+
+		if err := test.write(enc); err != nil {
+			t.Fatalf("%s: unable to write audio samples; %v", test.name, err)
+		}
+
+		if err := enc.Close(); err != nil {
+			t.Fatalf("%s: unable to close encoder; %v", test.name, err)
+		}
+
+		got, err := flac.ParseFile(outPath)
+		if err != nil {
+			t.Fatalf("%s: unable to parse output FLAC file; %v", test.name, err)
+		}
+		defer got.Close()
+
+		if got.Info.NSamples != stream.Info.NSamples {
+			t.Errorf("%s: sample count mismatch; expected %d, got %d", test.name, stream.Info.NSamples, got.Info.NSamples)
+		}
+
+		if got.Info.MD5sum != stream.Info.MD5sum {
+			t.Errorf("%s: MD5 checksum mismatch; expected %x, got %x", test.name, stream.Info.MD5sum, got.Info.MD5sum)
+		}
+
+		var samples []int32
+		for {
+			f, err := got.ParseNext()
+			if err != nil {
+				if err == io.EOF {
+					break
+				}
+				t.Fatalf("%s: unable to parse audio frame of output FLAC stream; %v", test.name, err)
+			}
+
+			for i := 0; i < int(f.BlockSize); i++ {
+				for _, subframe := range f.Subframes {
+					samples = append(samples, subframe.Samples[i])
+				}
+			}
+		}
+
+		if !reflect.DeepEqual(samples, interleaved) {
+			t.Errorf("%s: sample mismatch", test.name)
+		}
+	}
+}
+
+func TestEncodeWriteSamplesInvalid(t *testing.T) {
+	info := &meta.StreamInfo{
+		SampleRate:    44100,
+		NChannels:     2,
+		BitsPerSample: 16,
+	}
+
+	enc, err := flac.NewEncoderOptions(new(bytes.Buffer), info, flac.LevelOptions(flac.DefaultLevel))
+	if err != nil {
+		t.Fatalf("unable to create encoder; %v", err)
+	}
+
+	if _, err := enc.WriteSamples(make([]int32, 3)); err == nil {
+		t.Errorf("odd number of interleaved stereo samples; expected error, got nil")
+	}
+
+	if _, err := enc.WriteSamplesPlanar([][]int32{make([]int32, 2), make([]int32, 3)}); err == nil {
+		t.Errorf("mismatched channel lengths; expected error, got nil")
+	}
+}
+
+func TestEncodeVariableBlockSize(t *testing.T) {
+	// mono 16-bit sine wave, interrupted by a short burst of noise
+	const nsamples = 4*4096 + 1000
+	info := &meta.StreamInfo{
+		SampleRate:    44100,
+		NChannels:     1,
+		BitsPerSample: 16,
+	}
+
+	samples := make([]int32, nsamples)
+	seed := uint32(1)
+	for i := range samples {
+		samples[i] = int32(1000 * math.Sin(2*math.Pi*440*float64(i)/44100))
+		if i >= 6000 && i < 6300 {
+			seed = seed*1664525 + 1013904223
+			samples[i] += int32(seed) >> 18
+		}
+	}
+
+	opts := flac.LevelOptions(flac.DefaultLevel)
+	opts.VariableBlockSize = true
+	outPath := filepath.Join(t.TempDir(), "out.flac")
+	out, err := os.Create(outPath)
+	if err != nil {
+		t.Fatalf("unable to create output file; %v", err)
+	}
+
+	enc, err := flac.NewEncoderOptions(out, info, opts)
+	if err != nil {
+		t.Fatalf("unable to create encoder; %v", err)
+	}
+
+	if _, err := enc.WriteSamples(samples); err != nil {
+		t.Fatalf("unable to write audio samples; %v", err)
+	}
+
+	if err := enc.Close(); err != nil {
+		t.Fatalf("unable to close encoder; %v", err)
+	}
+
+	stream, err := flac.ParseFile(outPath)
+	if err != nil {
+		t.Fatalf("unable to parse output FLAC file; %v", err)
+	}
+	defer stream.Close()
+
+	var got []int32
+	var blockSizes []uint16
+	for {
+		f, err := stream.ParseNext()
+		if err != nil {
+			if err == io.EOF {
+				break
+			}
+			t.Fatalf("unable to parse audio frame of output FLAC stream; %v", err)
+		}
+
+		if f.HasFixedBlockSize {
+			t.Fatalf("frame %d: expected variable block size", len(blockSizes))
+		}
+
+		got = append(got, f.Subframes[0].Samples...)
+		blockSizes = append(blockSizes, f.BlockSize)
+	}
+
+	if !reflect.DeepEqual(got, samples) {
+		t.Fatalf("sample mismatch")
+	}
+
+	// the minimum block size excludes the last block
+	min, max := blockSizes[0], blockSizes[0]
+	for i, blockSize := range blockSizes {
+		if i < len(blockSizes)-1 && blockSize < min {
+			min = blockSize
+		}
+		if blockSize > max {
+			max = blockSize
+		}
+	}
+
+	if min >= opts.BlockSize {
+		t.Errorf("expected blocks shorter than %d around the transient; got block sizes %v", opts.BlockSize, blockSizes)
+	}
+
+	if stream.Info.BlockSizeMin != min || stream.Info.BlockSizeMax != max {
+		t.Errorf("block size range mismatch; expected [%d, %d], got [%d, %d]", min, max, stream.Info.BlockSizeMin, stream.Info.BlockSizeMax)
+	}
+}
+
+func TestEncodeWorkers(t *testing.T) {
+	paths := []string{
+		"testdata/172960.flac",
+		"testdata/love.flac",
+	}
+
+	for _, path := range paths {
+		stream, err := flac.ParseFile(path)
+		if err != nil {
+			t.Fatalf("%q: unable to parse FLAC file; %v", path, err)
+		}
+
+		var frames []*frame.Frame
+		for {
+			f, err := stream.ParseNext()
+			if err != nil {
+				if err == io.EOF {
+					break
+				}
+				t.Fatalf("%q: unable to parse audio frame of FLAC stream; %v", path, err)
+			}
+			frames = append(frames, f)
+		}
+		stream.Close()
+
+		// encode the same frames sequentially and concurrently
+		var outputs [][]byte
+		for _, workers := range []int{0, 4} {
+			opts := flac.LevelOptions(flac.MaxLevel)
+			opts.Workers = workers
+			outPath := filepath.Join(t.TempDir(), "out.flac")
+			out, err := os.Create(outPath)
+			if err != nil {
+				t.Fatalf("%q: unable to create output file; %v", path, err)
+			}
+
+			info := *stream.Info
+			enc, err := flac.NewEncoderOptions(out, &info, opts)
+			if err != nil {
+				t.Fatalf("%q: unable to create encoder; %v", path, err)
+			}
+
+			for _, f := range frames {
+				raw := &frame.Frame{Header: frame.Header{HasFixedBlockSize: f.HasFixedBlockSize}}
+				for _, subframe := range f.Subframes {
+					raw.Subframes = append(raw.Subframes, &frame.Subframe{Samples: subframe.Samples})
+				}
+
+				if err := enc.WriteFrame(raw); err != nil {
+					t.Fatalf("%q: unable to encode audio frame; %v", path, err)
+				}
+			}
+
+			if err := enc.Close(); err != nil {
+				t.Fatalf("%q: unable to close encoder; %v", path, err)
+			}
+
+			buf, err := os.ReadFile(outPath)
+			if err != nil {
+				t.Fatalf("%q: unable to read output file; %v", path, err)
+			}
+			outputs = append(outputs, buf)
+
+			if enc.Info.MD5sum != stream.Info.MD5sum {
+				t.Errorf("%q: MD5 checksum mismatch with %d workers; expected %x, got %x", path, workers, stream.Info.MD5sum, enc.Info.MD5sum)
+			}
+
+			if enc.Info.NSamples != stream.Info.NSamples {
+				t.Errorf("%q: sample count mismatch with %d workers; expected %d, got %d", path, workers, stream.Info.NSamples, enc.Info.NSamples)
+			}
+		}
+
+		if !bytes.Equal(outputs[0], outputs[1]) {
+			t.Errorf("%q: output of concurrent encoding differs from sequential encoding", path)
+		}
+	}
+}
+
+func TestEncodeWriteError(t *testing.T) {
+	info := &meta.StreamInfo{
+		SampleRate:    44100,
+		NChannels:     1,
+		BitsPerSample: 16,
+	}
+
+	for _, workers := range []int{0, 4} {
+		opts := flac.LevelOptions(flac.DefaultLevel)
+		opts.Workers = workers
+		before := runtime.NumGoroutine()
+		enc, err := flac.NewEncoderOptions(&failWriter{n: 1 << 16}, info, opts)
+		if err != nil {
+			t.Fatalf("workers %d: unable to create encoder; %v", workers, err)
+		}
+
+		// write frames of noise until the output stream fails
+		seed := uint32(1)
+		noise := func() *frame.Frame {
+			samples := make([]int32, opts.BlockSize)
+			for j := range samples {
+				seed = seed*1664525 + 1013904223
+				samples[j] = int32(seed) >> 16
+			}
+
+			return &frame.Frame{
+				Header:    frame.Header{HasFixedBlockSize: true},
+				Subframes: []*frame.Subframe{{Samples: samples}},
+			}
+		}
+
+		for i := 0; err == nil; i++ {
+			if i == 100 {
+				t.Fatalf("workers %d: expected write error, got nil", workers)
+			}
+			err = enc.WriteFrame(noise())
+		}
+
+		// the workers are stopped without calling Close
+		if after := runtime.NumGoroutine(); after > before {
+			t.Errorf("workers %d: goroutine count mismatch after write error; expected %d, got %d", workers, before, after)
+		}
+
+		// the first error is returned by subsequent writes, and by Close
+		if got := enc.WriteFrame(noise()); got != err {
+			t.Errorf("workers %d: WriteFrame error mismatch after write error; expected %v, got %v", workers, err, got)
+		}
+
+		if _, got := enc.WriteSamples(make([]int32, 16)); got != err {
+			t.Errorf("workers %d: WriteSamples error mismatch after write error; expected %v, got %v", workers, err, got)
+		}
+
+		if got := enc.Close(); got != err {
+			t.Errorf("workers %d: Close error mismatch after write error; expected %v, got %v", workers, err, got)
+		}
+	}
+}
+
+// failWriter fails every write once n bytes have been written.
+type failWriter struct {
+	n int
+}
+
+func (w *failWriter) Write(p []byte) (int, error) {
+	if len(p) > w.n {
+		return 0, errors.New("write failed")
+	}
+
+	w.n -= len(p)
+	return len(p), nil
+}
+
+func TestEncodeSeekTable(t *testing.T) {
+	const path = "testdata/love.flac"
+	stream, err := flac.ParseFile(path)
+	if err != nil {
+		t.Fatalf("%q: unable to parse FLAC file; %v", path, err)
+	}
+	defer stream.Close()
+
+	var samples []int32
+	for {
+		f, err := stream.ParseNext()
+		if err != nil {
+			if err == io.EOF {
+				break
+			}
+			t.Fatalf("%q: unable to parse audio frame of FLAC stream; %v", path, err)
+		}
+
+		for i := 0; i < int(f.BlockSize); i++ {
+			for _, subframe := range f.Subframes {
+				samples = append(samples, subframe.Samples[i])
+			}
+		}
+	}
+
+	tests := []struct {
+		interval        uint64
+		points          int
+		npoints, filled int
+	}{
+		// one seek point per frame containing a target sample
+		{interval: 8000, npoints: 6, filled: 6},
+		// more targets than frames; the remaining points are placeholders
+		{interval: 1000, npoints: 41, filled: 10},
+		// unknown number of samples; unused points are placeholders
+		{interval: 8000, points: 10, npoints: 10, filled: 6},
+		// unknown number of samples; targets beyond the last point are dropped
+		{interval: 8000, points: 3, npoints: 3, filled: 3},
+	}
+
+	for _, test := range tests {
+		opts := flac.LevelOptions(flac.DefaultLevel)
+		opts.SeekIntervalSamples = test.interval
+		opts.SeekPoints = test.points
+		outPath := filepath.Join(t.TempDir(), "out.flac")
+		out, err := os.Create(outPath)
+		if err != nil {
+			t.Fatalf("interval %d: unable to create output file; %v", test.interval, err)
+		}
+
+		info := *stream.Info
+		if test.points != 0 {
+			info.NSamples = 0
+		}
+
+		enc, err := flac.NewEncoderOptions(out, &info, opts, stream.Blocks[1:]...)
+		if err != nil {
+			t.Fatalf("interval %d: unable to create encoder; %v", test.interval, err)
+		}
+
+		if _, err := enc.WriteSamples(samples); err != nil {
+			t.Fatalf("interval %d: unable to write audio samples; %v", test.interval, err)
+		}
+
+		if err := enc.Close(); err != nil {
+			t.Fatalf("interval %d: unable to close encoder; %v", test.interval, err)
+		}
+
+		got, err := flac.ParseFile(outPath)
+		if err != nil {
+			t.Fatalf("interval %d: unable to parse output FLAC file; %v", test.interval, err)
+		}
+		defer got.Close()
+
+		var table *meta.SeekTable
+		dataStart := int64(4 + 4 + 34)
+		for _, block := range got.Blocks {
+			if t, ok := block.Body.(*meta.SeekTable); ok {
+				table = t
+			}
+			dataStart += 4 + block.Length
+		}
+
+		if table == nil || len(table.Points) != test.npoints {
+			t.Fatalf("interval %d: expected seek table of %d points, got %v", test.interval, test.npoints, table)
+		}
+
+		buf, err := os.ReadFile(outPath)
+		if err != nil {
+			t.Fatalf("interval %d: unable to read output file; %v", test.interval, err)
+		}
+
+		for i, point := range table.Points {
+			if i >= test.filled {
+				if point.SampleNum != meta.PlaceholderPoint {
+					t.Errorf("interval %d: seek point %d; expected placeholder, got %v", test.interval, i, point)
+				}
+				continue
+			}
+
+			// unless targets share frames, each seek point targets the frame containing its target sample
+			target := uint64(i) * test.interval
+			if test.filled == test.npoints && (point.SampleNum > target || point.SampleNum+uint64(point.NSamples) <= target) {
+				t.Errorf("interval %d: seek point %d (%v) does not contain target sample %d", test.interval, i, point, target)
+			}
+
+			f, err := frame.New(bytes.NewReader(buf[dataStart+int64(point.Offset):]))
+			if err != nil {
+				t.Fatalf("interval %d: unable to parse frame header at seek point %d; %v", test.interval, i, err)
+			}
+
+			if sampleNum := f.Num * uint64(opts.BlockSize); sampleNum != point.SampleNum || f.BlockSize != point.NSamples {
+				t.Errorf("interval %d: seek point %d mismatch; expected sample %d of %d samples, got %v", test.interval, i, sampleNum, f.BlockSize, point)
+			}
+		}
+	}
+}
+
+// writeSeeker wraps an io.WriteSeeker, hiding any other methods of the underlying writer.
+type writeSeeker struct {
+	io.WriteSeeker
+}
+
+func TestEncodeFrameSize(t *testing.T) {
+	paths := []string{
+		"testdata/172960.flac",
+		"testdata/19875.flac",
+		"testdata/love.flac",
+	}
+
+	for _, path := range paths {
+		stream, err := flac.ParseFile(path)
+		if err != nil {
+			t.Fatalf("%q: unable to parse FLAC file; %v", path, err)
+		}
+
+		outPath := filepath.Join(t.TempDir(), "out.flac")
+		out, err := os.Create(outPath)
+		if err != nil {
+			t.Fatalf("%q: unable to create output file; %v", path, err)
+		}
+
+		info := *stream.Info
+		info.FrameSizeMin, info.FrameSizeMax = 0, 0
+		enc, err := flac.NewEncoder(writeSeeker{out}, &info, stream.Blocks...)
+		if err != nil {
+			t.Fatalf("%q: unable to create encoder; %v", path, err)
+		}
+
+		for {
+			f, err := stream.ParseNext()
+			if err != nil {
+				if err == io.EOF {
+					break
+				}
+				t.Fatalf("%q: unable to parse audio frame of FLAC stream; %v", path, err)
+			}
+
+			if err := enc.WriteFrame(f); err != nil {
+				t.Fatalf("%q: unable to encode audio frame; %v", path, err)
+			}
+		}
+		stream.Close()
+
+		if err := enc.Close(); err != nil {
+			t.Fatalf("%q: unable to close encoder; %v", path, err)
+		}
+		out.Close()
+
+		// measure the size of each frame of the output stream
+		buf, err := os.ReadFile(outPath)
+		if err != nil {
+			t.Fatalf("%q: unable to read output file; %v", path, err)
+		}
+
+		got, err := flac.ParseFile(outPath)
+		if err != nil {
+			t.Fatalf("%q: unable to parse output FLAC file; %v", path, err)
+		}
+		got.Close()
+
+		dataStart := int64(4 + 4 + 34)
+		for _, block := range got.Blocks {
+			dataStart += 4 + block.Length
+		}
+
+		r := bytes.NewReader(buf[dataStart:])
+		var min, max uint32
+		for {
+			start := r.Len()
+			if _, err := frame.Parse(r); err != nil {
+				if err == io.EOF {
+					break
+				}
+				t.Fatalf("%q: unable to parse audio frame of output FLAC stream; %v", path, err)
+			}
+
+			size := uint32(start - r.Len())
+			if min == 0 || size < min {
+				min = size
+			}
+			if size > max {
+				max = size
+			}
+		}
+
+		if got.Info.FrameSizeMin != min || got.Info.FrameSizeMax != max {
+			t.Errorf("%q: frame size range mismatch; expected [%d, %d], got [%d, %d]", path, min, max, got.Info.FrameSizeMin, got.Info.FrameSizeMax)
+		}
+
+		// frames are encoded as in the source stream
+		if min != stream.Info.FrameSizeMin || max != stream.Info.FrameSizeMax {
+			t.Errorf("%q: frame size range mismatch with source; expected [%d, %d], got [%d, %d]", path, stream.Info.FrameSizeMin, stream.Info.FrameSizeMax, min, max)
+		}
+	}
+}
+
+func TestEncodeVerify(t *testing.T) {
+	for _, workers := range []int{0, 2} {
+		opts := flac.LevelOptions(flac.DefaultLevel)
+		opts.BlockSize = 1024
+		opts.Verify = true
+		opts.Workers = workers
+		info := &meta.StreamInfo{
+			SampleRate:    44100,
+			NChannels:     1,
+			BitsPerSample: 8,
+		}
+
+		enc, err := flac.NewEncoderOptions(new(bytes.Buffer), info, opts)
+		if err != nil {
+			t.Fatalf("workers %d: unable to create encoder; %v", workers, err)
+		}
+
+		// the first frame is valid, while the constant samples of the
+		// second frame exceed 8 bits-per-sample and are truncated when encoded
+		samples := make([]int32, 2*1024)
+		for i := range samples {
+			samples[i] = int32(i%200 - 100)
+			if i >= 1024 {
+				samples[i] = 300
+			}
+		}
+
+		_, err = enc.WriteSamples(samples)
+		if err == nil {
+			err = enc.Close()
+		}
+
+		var verr *flac.VerifyError
+		if !errors.As(err, &verr) {
+			t.Fatalf("workers %d: expected verify error, got %v", workers, err)
+		}
+
+		want := flac.VerifyError{Frame: 1, Channel: 0, Sample: 0, Want: 300, Got: 300 - 256}
+		if *verr != want {
+			t.Errorf("workers %d: verify error mismatch; expected %v, got %v", workers, &want, verr)
+		}
+	}
+}
+
+func TestEncodeSubset(t *testing.T) {
+	newInfo := func(sampleRate uint32, bps uint8) *meta.StreamInfo {
+		return &meta.StreamInfo{
+			SampleRate:    sampleRate,
+			NChannels:     1,
+			BitsPerSample: bps,
+		}
+	}
+
+	invalid := map[string]struct {
+		info   *meta.StreamInfo
+		modify func(opts *flac.EncoderOptions)
+	}{
+		"block size":      {newInfo(44100, 16), func(opts *flac.EncoderOptions) { opts.BlockSize = 8192 }},
+		"LPC order":       {newInfo(48000, 16), func(opts *flac.EncoderOptions) { opts.MaxLPCOrder = 16 }},
+		"partition order": {newInfo(96000, 24), func(opts *flac.EncoderOptions) { opts.MaxPartOrder = 9 }},
+		"sample rate":     {newInfo(700001, 16), func(opts *flac.EncoderOptions) {}},
+		"sample size":     {newInfo(44100, 18), func(opts *flac.EncoderOptions) {}},
+	}
+
+	for name, test := range invalid {
+		opts := flac.LevelOptions(flac.MaxLevel)
+		opts.Subset = true
+		test.modify(opts)
+		if _, err := flac.NewEncoderOptions(new(bytes.Buffer), test.info, opts); !errors.Is(err, flac.ErrNotSubset) {
+			t.Errorf("invalid %s; expected %v, got %v", name, flac.ErrNotSubset, err)
+		}
+	}
+
+	// larger blocks and LPC orders are allowed above 48 kHz
+	opts := flac.LevelOptions(flac.MaxLevel)
+	opts.Subset = true
+	opts.BlockSize = 16384
+	opts.MaxLPCOrder = 32
+	if _, err := flac.NewEncoderOptions(new(bytes.Buffer), newInfo(96000, 24), opts); err != nil {
+		t.Errorf("valid 96 kHz subset stream; unexpected error %v", err)
+	}
+
+	// 32-bit audio samples are encodable in frame headers
+	opts = flac.LevelOptions(flac.DefaultLevel)
+	opts.Subset = true
+	wide := new(bytes.Buffer)
+	enc, err := flac.NewEncoderOptions(wide, newInfo(44100, 32), opts)
+	if err != nil {
+		t.Fatalf("32-bit subset stream; unexpected error %v", err)
+	}
+
+	samples32 := make([]int32, 4096)
+	for i := range samples32 {
+		samples32[i] = int32(math.MaxInt32 * math.Sin(float64(i)/10))
+	}
+
+	if _, err := enc.WriteSamples(samples32); err != nil {
+		t.Fatalf("unable to write 32-bit audio samples; %v", err)
+	}
+
+	if err := enc.Close(); err != nil {
+		t.Fatalf("unable to close encoder; %v", err)
+	}
+
+	if got := decodeSamples(t, wide); !reflect.DeepEqual(got, [][]int32{samples32}) {
+		t.Errorf("32-bit subset stream; sample mismatch")
+	}
+
+	// frames exceeding the block size limit are rejected
+	opts = flac.LevelOptions(flac.DefaultLevel)
+	opts.Subset = true
+	enc, err = flac.NewEncoderOptions(new(bytes.Buffer), newInfo(44100, 16), opts)
+	if err != nil {
+		t.Fatalf("unable to create encoder; %v", err)
+	}
+
+	f := &frame.Frame{Subframes: []*frame.Subframe{{Samples: make([]int32, 8192)}}}
+	if err := enc.WriteFrame(f); !errors.Is(err, flac.ErrNotSubset) {
+		t.Errorf("frame block size; expected %v, got %v", flac.ErrNotSubset, err)
+	}
+
+	// subframe headers exceeding the LPC order and Rice partition order limits
+	// are rejected, if encoded without prediction analysis
+	subframes := map[string]frame.SubHeader{
+		"LPC order": {
+			Pred:                 frame.PredFIR,
+			Order:                16,
+			CoeffPrec:            15,
+			Coeffs:               make([]int32, 16),
+			ResidualCodingMethod: frame.ResidualCodingMethodRice1,
+			RiceSubframe:         &frame.RiceSubframe{Partitions: make([]frame.RicePartition, 1)},
+		},
+		"partition order": {
+			Pred:                 frame.PredFixed,
+			Order:                2,
+			ResidualCodingMethod: frame.ResidualCodingMethodRice1,
+			RiceSubframe:         &frame.RiceSubframe{PartOrder: 9, Partitions: make([]frame.RicePartition, 512)},
+		},
+	}
+
+	for name, subHdr := range subframes {
+		enc, err := flac.NewEncoderOptions(new(bytes.Buffer), newInfo(44100, 16), opts)
+		if err != nil {
+			t.Fatalf("unable to create encoder; %v", err)
+		}
+		enc.EnablePredictionAnalysis(false)
+
+		f := &frame.Frame{
+			Header: frame.Header{
+				HasFixedBlockSize: true,
+				BlockSize:         4096,
+				SampleRate:        44100,
+				Channels:          frame.ChannelsMono,
+				BitsPerSample:     16,
+			},
+			Subframes: []*frame.Subframe{{SubHeader: subHdr, Samples: make([]int32, 4096), NSamples: 4096}},
+		}
+		if err := enc.WriteFrame(f); !errors.Is(err, flac.ErrNotSubset) {
+			t.Errorf("subframe %s; expected %v, got %v", name, flac.ErrNotSubset, err)
+		}
+	}
+
+	// subset frames store the sample rate in the frame header
+	out := new(bytes.Buffer)
+	enc, err = flac.NewEncoderOptions(out, newInfo(44100, 16), opts)
+	if err != nil {
+		t.Fatalf("unable to create encoder; %v", err)
+	}
+
+	samples := make([]int32, 10000)
+	for i := range samples {
+		samples[i] = int32(1000 * math.Sin(float64(i)/10))
+	}
+
+	if _, err := enc.WriteSamples(samples); err != nil {
+		t.Fatalf("unable to write audio samples; %v", err)
+	}
+
+	if err := enc.Close(); err != nil {
+		t.Fatalf("unable to close encoder; %v", err)
+	}
+
+	stream, err := flac.New(out)
+	if err != nil {
+		t.Fatalf("unable to parse output FLAC stream; %v", err)
+	}
+
+	for {
+		f, err := stream.ParseNext()
+		if err != nil {
+			if err == io.EOF {
+				break
+			}
+			t.Fatalf("unable to parse audio frame of output FLAC stream; %v", err)
+		}
+
+		if f.SampleRate != 44100 || f.BitsPerSample != 16 {
+			t.Errorf("frame %d: expected sample rate and sample size in frame header, got %d Hz and %d bits", f.Num, f.SampleRate, f.BitsPerSample)
+		}
+
+		if f.BlockSize > 4608 {
+			t.Errorf("frame %d: block size %d exceeds subset limit", f.Num, f.BlockSize)
+		}
+	}
+}
+
+func TestEncode32Bit(t *testing.T) {
+	// loud 32-bit stereo signal, followed by alternating extremes
+	// whose prediction residuals overflow 32 bits
+	const nsamples = 10000
+	var samples []int32
+	for i := 0; i < nsamples; i++ {
+		l := int32(math.MaxInt32 * 0.9 * math.Sin(float64(i)/20))
+		r := int32(math.MaxInt32 * 0.9 * math.Cos(float64(i)/30))
+		if i >= nsamples-1000 {
+			l, r = math.MaxInt32, math.MinInt32
+			if i%2 == 0 {
+				l, r = r, l
+			}
+		}
+		samples = append(samples, l, r)
+	}
+
+	// MD5 checksum of 32-bit little-endian audio samples
+	md5sum := md5.New()
+	for _, sample := range samples {
+		binary.Write(md5sum, binary.LittleEndian, sample)
+	}
+	var want [md5.Size]byte
+	copy(want[:], md5sum.Sum(nil))
+
+	for _, level := range []int{-1, 0, flac.MaxLevel} {
+		info := &meta.StreamInfo{
+			SampleRate:    96000,
+			NChannels:     2,
+			BitsPerSample: 32,
+		}
+
+		// level -1 encodes verbatim subframes without options
+		var opts *flac.EncoderOptions
+		if level >= 0 {
+			opts = flac.LevelOptions(level)
+		}
+
+		path := filepath.Join(t.TempDir(), "out.flac")
+		out, err := os.Create(path)
+		if err != nil {
+			t.Fatalf("level %d: unable to create output file; %v", level, err)
+		}
+
+		enc, err := flac.NewEncoderOptions(out, info, opts)
+		if err != nil {
+			t.Fatalf("level %d: unable to create encoder; %v", level, err)
+		}
+
+		if _, err := enc.WriteSamples(samples); err != nil {
+			t.Fatalf("level %d: unable to write audio samples; %v", level, err)
+		}
+
+		if err := enc.Close(); err != nil {
+			t.Fatalf("level %d: unable to close encoder; %v", level, err)
+		}
+
+		stream, err := flac.ParseFile(path)
+		if err != nil {
+			t.Fatalf("level %d: unable to parse output FLAC file; %v", level, err)
+		}
+
+		if stream.Info.MD5sum != want {
+			t.Errorf("level %d: MD5 checksum mismatch; expected %x, got %x", level, want, stream.Info.MD5sum)
+		}
+
+		var got []int32
+		for {
+			f, err := stream.ParseNext()
+			if err != nil {
+				if err == io.EOF {
+					break
+				}
+				t.Fatalf("level %d: unable to parse audio frame of output FLAC stream; %v", level, err)
+			}
+
+			if f.BitsPerSample != 32 {
+				t.Errorf("level %d: frame %d sample size mismatch; expected 32, got %d", level, f.Num, f.BitsPerSample)
+			}
+
+			for i := 0; i < int(f.BlockSize); i++ {
+				for _, subframe := range f.Subframes {
+					got = append(got, subframe.Samples[i])
+				}
+			}
+		}
+		stream.Close()
+
+		if !reflect.DeepEqual(got, samples) {
+			t.Errorf("level %d: sample mismatch", level)
+		}
+	}
+
+	// the side channel of 32-bit audio samples requires 33 bits
+	out := new(bytes.Buffer)
+	enc, err := flac.NewEncoder(out, &meta.StreamInfo{BlockSizeMin: 16, BlockSizeMax: 16, SampleRate: 96000, NChannels: 2, BitsPerSample: 32})
+	if err != nil {
+		t.Fatalf("unable to create encoder; %v", err)
+	}
+
+	left := make([]int32, 16)
+	right := make([]int32, 16)
+	for i := range left {
+		left[i], right[i] = math.MaxInt32-int32(i), math.MinInt32+int32(i*i)
+		if i%2 == 0 {
+			left[i], right[i] = right[i], left[i]
+		}
+	}
+	f := &frame.Frame{
+		Header: frame.Header{
+			HasFixedBlockSize: true,
+			BlockSize:         uint16(len(left)),
+			SampleRate:        96000,
+			Channels:          frame.ChannelsMidSide,
+			BitsPerSample:     32,
+		},
+		Subframes: []*frame.Subframe{
+			{SubHeader: frame.SubHeader{Pred: frame.PredVerbatim}, Samples: left, NSamples: len(left)},
+			{SubHeader: frame.SubHeader{Pred: frame.PredVerbatim}, Samples: right, NSamples: len(right)},
+		},
+	}
+	if err := enc.WriteFrame(f); err != nil {
+		t.Fatalf("unable to encode mid/side frame of 32-bit audio samples; %v", err)
+	}
+
+	if err := enc.Close(); err != nil {
+		t.Fatalf("unable to close encoder; %v", err)
+	}
+
+	if got := decodeSamples(t, out); !reflect.DeepEqual(got, [][]int32{left, right}) {
+		t.Errorf("mid/side frame of 32-bit audio samples mismatch; expected %v, got %v", [][]int32{left, right}, got)
+	}
+}
+
+func TestEncode32BitStereo(t *testing.T) {
+	// full-scale 32-bit stereo noise; independent channels whose side channel
+	// exceeds 32 bits, nearly identical channels, and inverted channels whose
+	// side channel exceeds 32 bits with a wasted bit
+	const blockSize = 4096
+	var left, right []int32
+	seed := uint32(1)
+	noise := func() int32 {
+		seed = seed*1664525 + 1013904223
+		return int32(seed)
+	}
+	for i := 0; i < blockSize; i++ {
+		left, right = append(left, noise()), append(right, noise())
+	}
+	for i := 0; i < blockSize; i++ {
+		l := noise()
+		if l > math.MaxInt32-256 {
+			l = math.MaxInt32 - 256
+		}
+		left, right = append(left, l), append(right, l+noise()>>24)
+	}
+	for i := 0; i < blockSize; i++ {
+		l := noise()
+		if l == math.MinInt32 {
+			l++
+		}
+		left, right = append(left, l), append(right, -l)
+	}
+
+	for _, mode := range []flac.StereoMode{flac.StereoIndependent, flac.StereoAdaptive, flac.StereoExhaustive} {
+		opts := flac.LevelOptions(flac.MaxLevel)
+		opts.StereoMode = mode
+		opts.Verify = true
+		out := new(bytes.Buffer)
+		enc, err := flac.NewEncoderOptions(out, &meta.StreamInfo{SampleRate: 96000, NChannels: 2, BitsPerSample: 32}, opts)
+		if err != nil {
+			t.Fatalf("stereo mode %d: unable to create encoder; %v", mode, err)
+		}
+
+		if _, err := enc.WriteSamplesPlanar([][]int32{left, right}); err != nil {
+			t.Fatalf("stereo mode %d: unable to write audio samples; %v", mode, err)
+		}
+
+		if err := enc.Close(); err != nil {
+			t.Fatalf("stereo mode %d: unable to close encoder; %v", mode, err)
+		}
+
+		stream, err := flac.New(out)
+		if err != nil {
+			t.Fatalf("stereo mode %d: unable to parse output FLAC stream; %v", mode, err)
+		}
+
+		var got [2][]int32
+		var channels []frame.Channels
+		for {
+			f, err := stream.ParseNext()
+			if err != nil {
+				if err == io.EOF {
+					break
+				}
+				t.Fatalf("stereo mode %d: unable to parse audio frame of output FLAC stream; %v", mode, err)
+			}
+
+			channels = append(channels, f.Channels)
+			for i, subframe := range f.Subframes {
+				got[i] = append(got[i], subframe.Samples...)
+			}
+		}
+
+		if !reflect.DeepEqual(got, [2][]int32{left, right}) {
+			t.Errorf("stereo mode %d: sample mismatch", mode)
+		}
+
+		// independent channels, followed by inter-channel decorrelation;
+		// the adaptive stereo mode only estimates the cheapest decorrelation
+		want := []frame.Channels{frame.ChannelsLR, frame.ChannelsLR, frame.ChannelsLR}
+		switch mode {
+		case flac.StereoAdaptive:
+			if channels[1] == frame.ChannelsLR || channels[2] == frame.ChannelsLR {
+				t.Errorf("stereo mode %d: expected inter-channel decorrelation of correlated channels, got %v", mode, channels)
+			}
+		case flac.StereoExhaustive:
+			want = []frame.Channels{frame.ChannelsLR, frame.ChannelsLeftSide, frame.ChannelsMidSide}
+			fallthrough
+		default:
+			if !reflect.DeepEqual(channels, want) {
+				t.Errorf("stereo mode %d: channel assignment mismatch; expected %v, got %v", mode, want, channels)
+			}
+		}
+	}
+}
+
+func TestEncodeAppend(t *testing.T) {
+	// 16-bit stereo signal, encoded in two chunks
+	const nsamples = 20000
+	const split = 4096 * 3
+	var samples []int32
+	for i := 0; i < nsamples; i++ {
+		samples = append(samples, int32(10000*math.Sin(float64(i)/10)), int32(5000*math.Sin(float64(i)/7)))
+	}
+
+	newInfo := func() *meta.StreamInfo {
+		return &meta.StreamInfo{SampleRate: 44100, NChannels: 2, BitsPerSample: 16}
+	}
+
+	encodeFile := func(path string, opts *flac.EncoderOptions, samples []int32) {
+		out, err := os.Create(path)
+		if err != nil {
+			t.Fatalf("unable to create output file; %v", err)
+		}
+
+		enc, err := flac.NewEncoderOptions(out, newInfo(), opts)
+		if err != nil {
+			t.Fatalf("unable to create encoder; %v", err)
+		}
+
+		if _, err := enc.WriteSamples(samples); err != nil {
+			t.Fatalf("unable to write audio samples; %v", err)
+		}
+
+		if err := enc.Close(); err != nil {
+			t.Fatalf("unable to close encoder; %v", err)
+		}
+	}
+
+	// MD5 running hash of the audio samples of the first chunk
+	stated := md5.New()
+	for _, sample := range samples[:2*split] {
+		binary.Write(stated, binary.LittleEndian, int16(sample))
+	}
+
+	variable := flac.LevelOptions(flac.DefaultLevel)
+	variable.VariableBlockSize = true
+	tests := []struct {
+		name   string
+		opts   *flac.EncoderOptions
+		md5sum hash.Hash
+	}{
+		{name: "fixed decoded", opts: flac.LevelOptions(flac.DefaultLevel)},
+		{name: "fixed stated", opts: flac.LevelOptions(flac.DefaultLevel), md5sum: stated},
+		{name: "variable decoded", opts: variable},
+	}
+
+	for _, test := range tests {
+		dir := t.TempDir()
+		wantPath := filepath.Join(dir, "want.flac")
+		encodeFile(wantPath, test.opts, samples)
+
+		path := filepath.Join(dir, "out.flac")
+		encodeFile(path, test.opts, samples[:2*split])
+		f, err := os.OpenFile(path, os.O_RDWR, 0)
+		if err != nil {
+			t.Fatalf("%s: unable to open output file; %v", test.name, err)
+		}
+
+		enc, err := flac.NewAppendEncoder(f, test.opts, test.md5sum)
+		if err != nil {
+			t.Fatalf("%s: unable to create append encoder; %v", test.name, err)
+		}
+
+		if _, err := enc.WriteSamples(samples[2*split:]); err != nil {
+			t.Fatalf("%s: unable to write audio samples; %v", test.name, err)
+		}
+
+		if err := enc.Close(); err != nil {
+			t.Fatalf("%s: unable to close encoder; %v", test.name, err)
+		}
+
+		want, err := os.ReadFile(wantPath)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		got, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// appending the second chunk produces the same stream as
+		// encoding both chunks at once, unless block sizes are split differently
+		if test.opts.VariableBlockSize {
+			wantStream, err := flac.New(bytes.NewReader(want))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			gotStream, err := flac.New(bytes.NewReader(got))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if gotStream.Info.NSamples != nsamples || gotStream.Info.MD5sum != wantStream.Info.MD5sum {
+				t.Errorf("%s: StreamInfo mismatch; expected %d samples and MD5 %x, got %d samples and MD5 %x", test.name, nsamples, wantStream.Info.MD5sum, gotStream.Info.NSamples, gotStream.Info.MD5sum)
+			}
+
+			var sampleNum uint64
+			for {
+				frame, err := gotStream.ParseNext()
+				if err != nil {
+					if err == io.EOF {
+						break
+					}
+					t.Fatalf("%s: unable to parse audio frame; %v", test.name, err)
+				}
+
+				if frame.Num != sampleNum {
+					t.Errorf("%s: frame sample number mismatch; expected %d, got %d", test.name, sampleNum, frame.Num)
+				}
+				sampleNum += uint64(frame.BlockSize)
+			}
+		} else if !bytes.Equal(got, want) {
+			t.Errorf("%s: output mismatch", test.name)
+		}
+	}
+
+	// fixed block size streams ending in a partial block can't be appended to
+	path := filepath.Join(t.TempDir(), "partial.flac")
+	encodeFile(path, flac.LevelOptions(flac.DefaultLevel), samples[:2*(split+100)])
+	f, err := os.OpenFile(path, os.O_RDWR, 0)
+	if err != nil {
+		t.Fatalf("unable to open output file; %v", err)
+	}
+	defer f.Close()
+
+	if _, err := flac.NewAppendEncoder(f, flac.LevelOptions(flac.DefaultLevel), nil); err == nil {
+		t.Error("expected error for stream of fixed block size ending in partial block")
+	}
+}
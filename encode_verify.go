@@ -0,0 +1,81 @@
+package flac
+
+import (
+	"bytes"
+	"fmt"
+
+	"github.com/pchchv/flac/frame"
+)
+
+// VerifyError is returned by WriteFrame in verify mode if an encoded
+// audio frame does not decode to the audio samples of the input frame.
+type VerifyError struct {
+	// Number of the frame, counting the frames written by the encoder from 0.
+	Frame uint64
+	// Channel and index within the frame of the first differing audio sample;
+	// -1 if not applicable to the mismatch.
+	Channel, Sample int
+	// Input audio sample and decoded audio sample.
+	Want, Got int32
+	// Error encountered while decoding the encoded frame, if any.
+	Err error
+}
+
+// Error returns a description of the mismatch.
+func (e *VerifyError) Error() string {
+	if e.Err != nil {
+		return fmt.Sprintf("verification of frame %d failed; unable to decode encoded frame; %v", e.Frame, e.Err)
+	}
+
+	return fmt.Sprintf("verification of frame %d failed; sample %d of channel %d mismatch; expected %d, got %d", e.Frame, e.Sample, e.Channel, e.Want, e.Got)
+}
+
+// Unwrap returns the error encountered while decoding the encoded frame.
+func (e *VerifyError) Unwrap() error {
+	return e.Err
+}
+
+// encodeVerifiedFrame encodes the given prepared audio frame, holding the
+// given frame number, and verifies the encoded frame if in verify mode.
+func (enc *Encoder) encodeVerifiedFrame(f *frame.Frame, num uint64) ([]byte, error) {
+	buf, err := enc.encodeFrame(f)
+	if err != nil {
+		return nil, err
+	}
+
+	if enc.opts != nil && enc.opts.Verify {
+		if err := verifyFrame(buf, f, num); err != nil {
+			return nil, err
+		}
+	}
+
+	return buf, nil
+}
+
+// verifyFrame decodes the given encoded audio frame, and compares its
+// audio samples with those of the input frame f, which holds the given frame number.
+func verifyFrame(buf []byte, f *frame.Frame, num uint64) error {
+	decoded, err := frame.Parse(bytes.NewReader(buf))
+	if err != nil {
+		return &VerifyError{Frame: num, Channel: -1, Sample: -1, Err: err}
+	}
+
+	if len(decoded.Subframes) != len(f.Subframes) {
+		return &VerifyError{Frame: num, Channel: -1, Sample: -1, Err: fmt.Errorf("subframe count mismatch; expected %d, got %d", len(f.Subframes), len(decoded.Subframes))}
+	}
+
+	for channel, subframe := range f.Subframes {
+		got := decoded.Subframes[channel].Samples
+		if len(got) != len(subframe.Samples) {
+			return &VerifyError{Frame: num, Channel: channel, Sample: -1, Err: fmt.Errorf("sample count mismatch; expected %d, got %d", len(subframe.Samples), len(got))}
+		}
+
+		for i, want := range subframe.Samples {
+			if got[i] != want {
+				return &VerifyError{Frame: num, Channel: channel, Sample: i, Want: want, Got: got[i]}
+			}
+		}
+	}
+
+	return nil
+}
@@ -6,6 +6,7 @@ import (
 	"bytes"
 	"errors"
 	"fmt"
+	"hash"
 	"io"
 	"os"
 
@@ -14,8 +15,6 @@ import (
 	"github.com/pchchv/flac/meta"
 )
 
-const defaultSeekTableSize = 100
-
 var (
 	flacSignature  = []byte("fLaC")                                                 // marks the beginning of a FLAC stream
 	id3Signature   = []byte("ID3")                                                  // marks the beginning of an ID3 stream, used to skip over ID3 data
@@ -35,16 +34,34 @@ type Stream struct {
 	// more pre-calculated audio frame seek points of the stream;
 	// nil if uninitialized.
 	seekTable *meta.SeekTable
-	// seekTableSize determines how many seek points
-	// the seekTable should have if the
-	// flac file does not include one in the metadata.
-	seekTableSize int
 	// dataStart is the offset of the
 	// first frame header since SeekPoint.Offset
 	// is relative to this position.
 	dataStart int64
 	// Underlying io.Reader, or io.ReadCloser.
 	r io.Reader
+	// Audio frame being read by ReadSamples and ReadSamplesPlanar,
+	// and the number of its interleaved samples read; nil if none.
+	frame    *frame.Frame
+	framePos int
+	// Lost-sync recovery of ParseNext is enabled, and the function called
+	// for each loss of sync recovered from; see EnableResync.
+	resync     bool
+	onSyncLoss func(SyncLoss)
+	// Sample number following the last frame decoded; frames found after
+	// a loss of sync in resync mode must start at or after it.
+	nextSample uint64
+	// State of the error-concealing mode; nil if disabled.
+	conceal *concealer
+	// Running MD5 hash of the decoded audio samples; nil if not verified.
+	md5sum hash.Hash
+	// Buffered reader and frame used to locate frames by bisection,
+	// reused across seeks; nil until first used.
+	seekBuf   *bufio.Reader
+	seekFrame *frame.Frame
+	// Reader of the frames peeked in resync mode,
+	// reused across frames; nil until first used.
+	peekReader *bytes.Reader
 }
 
 // New creates a new Stream for accessing the audio samples of r.
@@ -83,7 +100,7 @@ func New(r io.Reader) (stream *Stream, err error) {
 // Using an in-memory buffer like *bytes.Reader should work well.
 func NewSeek(rs io.ReadSeeker) (stream *Stream, err error) {
 	br := bufseekio.NewReadSeeker(rs)
-	stream = &Stream{r: br, seekTableSize: defaultSeekTableSize}
+	stream = &Stream{r: br}
 	// verify FLAC signature and parse the StreamInfo metadata block
 	block, err := stream.parseStreamInfo()
 	if err != nil {
@@ -126,31 +143,154 @@ func (stream *Stream) Close() error {
 //
 // Call Frame.Parse to parse the audio samples of its subframes.
 func (stream *Stream) Next() (f *frame.Frame, err error) {
-	return frame.New(stream.r)
+	f, err = frame.New(stream.r)
+	if err == nil {
+		stream.setNextSample(f)
+	}
+
+	return f, err
 }
 
 // ParseNext parses the entire next frame including audio samples.
-// Returns io.EOF to signal a graceful end of FLAC stream.
+// Returns io.EOF to signal a graceful end of FLAC stream;
+// or an error wrapping ErrMD5Mismatch, as described by EnableMD5Check.
 func (stream *Stream) ParseNext() (f *frame.Frame, err error) {
-	return frame.Parse(stream.r)
+	f, err = stream.parseNext()
+	if stream.md5sum != nil {
+		return stream.verifyMD5(f, err)
+	}
+
+	return f, err
+}
+
+// ParseNextInto parses the entire next frame including audio samples into f,
+// reusing the subframes and buffers of f as parsed by previous calls;
+// the audio samples of the previous frame are overwritten.
+// Returns io.EOF to signal a graceful end of FLAC stream.
+//
+// Once the buffers of f hold the largest block size of the stream, no memory
+// is allocated; except in error-concealing mode, in which the audio samples
+// of the frame parsed by ParseNext are copied into the buffers of f.
+func (stream *Stream) ParseNextInto(f *frame.Frame) error {
+	var err error
+	switch {
+	case stream.conceal != nil:
+		var g *frame.Frame
+		if g, err = stream.parseNextConceal(); err == nil {
+			copyFrame(f, g)
+		}
+	case stream.resync:
+		_, err = stream.parseNextResync(f)
+	default:
+		if err = frame.ParseInto(stream.r, f); err == nil {
+			stream.setNextSample(f)
+		}
+	}
+
+	if stream.md5sum != nil {
+		_, err = stream.verifyMD5(f, err)
+	}
+
+	return err
+}
+
+// parseNext parses the entire next frame including audio samples,
+// in the decoding mode of the stream.
+func (stream *Stream) parseNext() (*frame.Frame, error) {
+	switch {
+	case stream.conceal != nil:
+		return stream.parseNextConceal()
+	case stream.resync:
+		return stream.parseNextResync(new(frame.Frame))
+	}
+
+	f, err := frame.Parse(stream.r)
+	if err == nil {
+		stream.setNextSample(f)
+	}
+
+	return f, err
+}
+
+// setNextSample records the sample number following the given frame,
+// as the last frame decoded.
+func (stream *Stream) setNextSample(f *frame.Frame) {
+	stream.nextSample = stream.sampleNumber(f) + uint64(f.BlockSize)
+}
+
+// copyFrame copies the header and audio samples of src into dst,
+// reusing the subframes and sample buffers of dst.
+func copyFrame(dst, src *frame.Frame) {
+	dst.Header = src.Header
+	n := len(src.Subframes)
+	if cap(dst.Subframes) < n {
+		dst.Subframes = make([]*frame.Subframe, n)
+	}
+
+	dst.Subframes = dst.Subframes[:n]
+	for channel, subframe := range src.Subframes {
+		if dst.Subframes[channel] == nil {
+			dst.Subframes[channel] = new(frame.Subframe)
+		}
+		d := dst.Subframes[channel]
+		d.SubHeader = subframe.SubHeader
+		d.Samples = append(d.Samples[:0], subframe.Samples...)
+		d.NSamples = subframe.NSamples
+	}
 }
 
 // Seek seeks to the frame containing the given absolute sample number.
 // The return value specifies the
 // first sample number of the frame containing sampleNum.
+//
+// If the stream has no seek table, the frame is located by
+// bisection on the byte offset of frames.
+//
+// Streams created by New and Parse only support seeking forward, by skipping
+// frames from the start of the next frame; ErrNoSeeker is returned
+// if sampleNum precedes the next frame.
 func (stream *Stream) Seek(sampleNum uint64) (uint64, error) {
-	if stream.seekTable == nil && stream.seekTableSize > 0 {
-		if err := stream.makeSeekTable(); err != nil {
-			return 0, err
-		}
+	first, err := stream.seek(sampleNum)
+	if err != nil {
+		return 0, err
+	}
+
+	// frames preceding the sought frame are not lost in resync mode,
+	// nor concealed in error-concealing mode
+	stream.nextSample = first
+	if c := stream.conceal; c != nil {
+		c.last, c.next, c.pending, c.pendingErr = nil, first, nil, nil
 	}
 
-	rs := stream.r.(io.ReadSeeker)
+	// the audio samples preceding the sought frame are not hashed
+	stream.md5sum = nil
+
+	return first, nil
+}
+
+// seek seeks to the frame containing the given absolute sample number,
+// as described by Seek.
+func (stream *Stream) seek(sampleNum uint64) (uint64, error) {
 	isBiggerThanStream := stream.Info.NSamples != 0 && sampleNum >= stream.Info.NSamples
 	if isBiggerThanStream || sampleNum < 0 {
 		return 0, fmt.Errorf("unable to seek to sample number %d", sampleNum)
 	}
 
+	rs, ok := stream.r.(io.ReadSeeker)
+	if !ok {
+		br, ok := stream.r.(*bufio.Reader)
+		if prev := stream.frame; !ok || prev != nil && sampleNum < stream.sampleNumber(prev)+uint64(prev.BlockSize) {
+			return 0, ErrNoSeeker
+		}
+		return stream.skip(br, sampleNum)
+	}
+
+	// discard the audio frame being read by ReadSamples
+	stream.frame, stream.framePos = nil, 0
+	if stream.seekTable == nil || len(stream.seekTable.Points) == 0 {
+		return stream.bisect(rs, sampleNum)
+	}
+
 	point, err := stream.searchFromStart(sampleNum)
 	if err != nil {
 		return 0, err
@@ -172,14 +312,51 @@ func (stream *Stream) Seek(sampleNum uint64) (uint64, error) {
 			return 0, err
 		}
 
-		if frame.SampleNumber()+uint64(frame.BlockSize) > sampleNum {
+		if first := stream.sampleNumber(frame); first+uint64(frame.BlockSize) > sampleNum {
 			// restore seek offset to the start of the frame containing the specified sample number
 			_, err := rs.Seek(offset, io.SeekStart)
-			return frame.SampleNumber(), err
+			return first, err
 		}
 	}
 }
 
+// sampleNumber returns the first sample number contained within the given frame.
+// Unlike frame.Frame.SampleNumber, it accounts for the partial last block
+// of a stream of fixed block size, using the block size of StreamInfo.
+func (stream *Stream) sampleNumber(f *frame.Frame) uint64 {
+	if f.HasFixedBlockSize && stream.Info.BlockSizeMin == stream.Info.BlockSizeMax {
+		return f.Num * uint64(stream.Info.BlockSizeMax)
+	}
+
+	return f.SampleNumber()
+}
+
+// SeekSample seeks to the given absolute sample number, such that
+// the next sample position read by ReadSamples or ReadSamplesPlanar
+// is exactly sampleNum. The leading samples of the frame containing
+// sampleNum are decoded and discarded.
+func (stream *Stream) SeekSample(sampleNum uint64) error {
+	// the frame being read by ReadSamples may already contain sampleNum
+	if f := stream.frame; f != nil && stream.hasSample(f, sampleNum) {
+		stream.framePos = int(sampleNum-stream.sampleNumber(f)) * len(f.Subframes)
+		return nil
+	}
+
+	first, err := stream.Seek(sampleNum)
+	if err != nil {
+		return err
+	}
+
+	f, err := stream.ParseNext()
+	if err != nil {
+		return err
+	}
+
+	stream.frame = f
+	stream.framePos = int(sampleNum-first) * len(f.Subframes)
+	return nil
+}
+
 // skipID3v2 skips ID3v2 data prepended to flac files.
 func (stream *Stream) skipID3v2() error {
 	r := bufio.NewReader(stream.r)
@@ -266,56 +443,6 @@ func (stream *Stream) searchFromStart(sampleNum uint64) (meta.SeekPoint, error)
 	return prev, nil
 }
 
-// makeSeekTable creates a seek table with seek points to
-// each frame of the FLAC stream.
-func (stream *Stream) makeSeekTable() (err error) {
-	rs, ok := stream.r.(io.ReadSeeker)
-	if !ok {
-		return ErrNoSeeker
-	}
-
-	pos, err := rs.Seek(0, io.SeekCurrent)
-	if err != nil {
-		return err
-	}
-
-	_, err = rs.Seek(stream.dataStart, io.SeekStart)
-	if err != nil {
-		return err
-	}
-
-	var i int
-	var sampleNum uint64
-	var points []meta.SeekPoint
-	for {
-		// record seek offset to start of frame
-		off, err := rs.Seek(0, io.SeekCurrent)
-		if err != nil {
-			return err
-		}
-
-		f, err := stream.ParseNext()
-		if err != nil {
-			if err == io.EOF {
-				break
-			}
-			return err
-		}
-
-		points = append(points, meta.SeekPoint{
-			SampleNum: sampleNum,
-			Offset:    uint64(off - stream.dataStart),
-			NSamples:  f.BlockSize,
-		})
-		sampleNum += uint64(f.BlockSize)
-		i++
-	}
-
-	stream.seekTable = &meta.SeekTable{Points: points}
-	_, err = rs.Seek(pos, io.SeekStart)
-	return err
-}
-
 // Parse creates a new Stream for accessing the metadata blocks and audio samples of r.
 // It reads and parses the FLAC signature and all metadata blocks.
 //
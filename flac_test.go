@@ -1,12 +1,20 @@
 package flac_test
 
 import (
+	"bytes"
+	"crypto/md5"
+	"errors"
 	"fmt"
 	"io"
+	"math"
 	"os"
+	"reflect"
 	"testing"
 
 	"github.com/pchchv/flac"
+	"github.com/pchchv/flac/frame"
+	"github.com/pchchv/flac/internal/hashutil/crc8"
+	"github.com/pchchv/flac/meta"
 )
 
 func TestSkipID3v2(t *testing.T) {
@@ -193,3 +201,749 @@ func TestDecode(t *testing.T) {
 		}
 	}
 }
+
+// decodeChannels decodes the FLAC file of path and returns the audio samples of each channel.
+func decodeChannels(t *testing.T, path string) [][]int32 {
+	stream, err := flac.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stream.Close()
+
+	channels := make([][]int32, stream.Info.NChannels)
+	for {
+		f, err := stream.ParseNext()
+		if err != nil {
+			if err == io.EOF {
+				break
+			}
+			t.Fatal(err)
+		}
+
+		for channel, subframe := range f.Subframes {
+			channels[channel] = append(channels[channel], subframe.Samples...)
+		}
+	}
+
+	return channels
+}
+
+func TestReadSamples(t *testing.T) {
+	const path = "testdata/172960.flac"
+	want := decodeChannels(t, path)
+	nchannels := len(want)
+
+	for _, size := range []int{1, 7, 1000, 4096, 10000} {
+		t.Run(fmt.Sprintf("interleaved/%d", size), func(t *testing.T) {
+			stream, err := flac.Open(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer stream.Close()
+
+			var got []int32
+			buf := make([]int32, size)
+			for {
+				n, err := stream.ReadSamples(buf)
+				got = append(got, buf[:n]...)
+				if err != nil {
+					if err == io.EOF {
+						break
+					}
+					t.Fatal(err)
+				}
+			}
+
+			if len(got) != nchannels*len(want[0]) {
+				t.Fatalf("sample count mismatch; expected %d, got %d", nchannels*len(want[0]), len(got))
+			}
+
+			for i, sample := range got {
+				if expected := want[i%nchannels][i/nchannels]; sample != expected {
+					t.Fatalf("sample %d mismatch; expected %d, got %d", i, expected, sample)
+				}
+			}
+		})
+
+		t.Run(fmt.Sprintf("planar/%d", size), func(t *testing.T) {
+			stream, err := flac.Open(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer stream.Close()
+
+			got := make([][]int32, nchannels)
+			buf := make([][]int32, nchannels)
+			for channel := range buf {
+				buf[channel] = make([]int32, size)
+			}
+
+			for {
+				n, err := stream.ReadSamplesPlanar(buf)
+				for channel := range got {
+					got[channel] = append(got[channel], buf[channel][:n]...)
+				}
+				if err != nil {
+					if err == io.EOF {
+						break
+					}
+					t.Fatal(err)
+				}
+			}
+
+			if !reflect.DeepEqual(got, want) {
+				t.Fatal("sample mismatch")
+			}
+		})
+	}
+}
+
+func TestSeekSample(t *testing.T) {
+	const path = "testdata/172960.flac"
+	want := decodeChannels(t, path)
+	nchannels := len(want)
+
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	stream, err := flac.NewSeek(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	buf := make([]int32, 10*nchannels)
+	for _, sampleNum := range []uint64{9000, 0, 1, 4095, 4096, 8191, 40960, 43680, 100} {
+		if err := stream.SeekSample(sampleNum); err != nil {
+			t.Fatalf("sample %d: unable to seek; %v", sampleNum, err)
+		}
+
+		n, err := stream.ReadSamples(buf)
+		if err != nil {
+			t.Fatalf("sample %d: unable to read samples; %v", sampleNum, err)
+		}
+
+		// samples are read across frame boundaries up to the end of the stream
+		if rest := (len(want[0]) - int(sampleNum)) * nchannels; rest < len(buf) && n != rest {
+			t.Errorf("sample %d: sample count mismatch; expected %d, got %d", sampleNum, rest, n)
+		}
+
+		for i, sample := range buf[:n] {
+			if expected := want[i%nchannels][int(sampleNum)+i/nchannels]; sample != expected {
+				t.Fatalf("sample %d: sample %d mismatch; expected %d, got %d", sampleNum, i, expected, sample)
+			}
+		}
+	}
+
+	if err := stream.SeekSample(43683); err == nil {
+		t.Error("expected error for sample number beyond end of stream")
+	}
+}
+
+// encodeNoise returns the audio samples and FLAC stream of a noisy stereo signal,
+// encoded with a variable block size and without a seek table;
+// its frames are likely to contain false sync codes.
+func encodeNoise(t *testing.T) ([]int32, []byte) {
+	const nsamples = 100000
+	var samples []int32
+	x := uint32(1)
+	for i := 0; i < nsamples; i++ {
+		x = x*1664525 + 1013904223
+		samples = append(samples, int32(x>>16)-32768, int32(20000*math.Sin(float64(i)/(float64(i%5000)+10))))
+	}
+
+	opts := flac.LevelOptions(flac.DefaultLevel)
+	opts.VariableBlockSize = true
+	info := &meta.StreamInfo{SampleRate: 44100, NChannels: 2, BitsPerSample: 16}
+	out := new(bytes.Buffer)
+	enc, err := flac.NewEncoderOptions(out, info, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := enc.WriteSamples(samples); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := enc.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	return samples, out.Bytes()
+}
+
+// encodeWideSide encodes a 32-bit stereo stream of full-scale noise, whose
+// left-side decorrelated frames have a side channel exceeding 32 bits.
+func encodeWideSide(t *testing.T) ([]int32, []byte) {
+	const blockSize, nframes = 4096, 8
+	info := &meta.StreamInfo{BlockSizeMin: blockSize, BlockSizeMax: blockSize, SampleRate: 96000, NChannels: 2, BitsPerSample: 32}
+	out := new(bytes.Buffer)
+	enc, err := flac.NewEncoder(out, info)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var samples []int32
+	x := uint32(1)
+	for i := 0; i < nframes; i++ {
+		left := make([]int32, blockSize)
+		right := make([]int32, blockSize)
+		for j := range left {
+			x = x*1664525 + 1013904223
+			left[j] = int32(x)
+			x = x*1664525 + 1013904223
+			right[j] = int32(x)
+			samples = append(samples, left[j], right[j])
+		}
+
+		f := &frame.Frame{
+			Header: frame.Header{
+				HasFixedBlockSize: true,
+				BlockSize:         blockSize,
+				Channels:          frame.ChannelsLeftSide,
+				BitsPerSample:     32,
+			},
+			Subframes: []*frame.Subframe{
+				{SubHeader: frame.SubHeader{Pred: frame.PredVerbatim}, Samples: left, NSamples: blockSize},
+				{SubHeader: frame.SubHeader{Pred: frame.PredVerbatim}, Samples: right, NSamples: blockSize},
+			},
+		}
+		if err := enc.WriteFrame(f); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := enc.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	return samples, out.Bytes()
+}
+
+func TestSeekBisect(t *testing.T) {
+	samples, data := encodeNoise(t)
+	stream, err := flac.NewSeek(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	buf := make([]int32, 2)
+	for _, sampleNum := range []uint64{0, 99999, 50000, 1, 4095, 4096, 77777, 12345, 99000, 3} {
+		if err := stream.SeekSample(sampleNum); err != nil {
+			t.Fatalf("sample %d: unable to seek; %v", sampleNum, err)
+		}
+
+		if _, err := stream.ReadSamples(buf); err != nil {
+			t.Fatalf("sample %d: unable to read samples; %v", sampleNum, err)
+		}
+
+		if want := samples[2*sampleNum : 2*sampleNum+2]; !reflect.DeepEqual(buf, want) {
+			t.Errorf("sample %d: sample mismatch; expected %v, got %v", sampleNum, want, buf)
+		}
+	}
+}
+
+func TestSeekBisectFalseSync(t *testing.T) {
+	// verbatim 16-bit mono stream of 8 frames, whose audio samples embed
+	// frame headers of the partial last frame with a valid CRC-8 checksum
+	const blockSize, nframes = 4096, 8
+	fake := []byte{0xFF, 0xF8, 0xC9, 0x08, nframes - 1}
+	fake = append(fake, crc8.ChecksumATM(fake))
+	samples := make([]int32, blockSize*(nframes-1)+1000)
+	for i := range samples {
+		samples[i] = int32(10000 * math.Sin(float64(i)/10))
+		if j := i % 512; j < len(fake)/2 && i < blockSize*(nframes-1) {
+			samples[i] = int32(int16(uint16(fake[2*j])<<8 | uint16(fake[2*j+1])))
+		}
+	}
+
+	info := &meta.StreamInfo{BlockSizeMin: blockSize, BlockSizeMax: blockSize, SampleRate: 44100, NChannels: 1, BitsPerSample: 16}
+	out := new(bytes.Buffer)
+	enc, err := flac.NewEncoder(out, info)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := enc.WriteSamples(samples); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := enc.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	stream, err := flac.NewSeek(bytes.NewReader(out.Bytes()))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, sampleNum := range []uint64{20000, 5000, 29000, 0, 12345} {
+		first, err := stream.Seek(sampleNum)
+		if err != nil {
+			t.Fatalf("sample %d: unable to seek; %v", sampleNum, err)
+		}
+
+		if want := sampleNum / blockSize * blockSize; first != want {
+			t.Errorf("sample %d: first sample number mismatch; expected %d, got %d", sampleNum, want, first)
+		}
+	}
+}
+
+func TestSeekBisectReads(t *testing.T) {
+	// verbatim 16-bit mono stream of 16 large frames
+	const blockSize, nframes = 32768, 16
+	samples := make([]int32, blockSize*nframes)
+	for i := range samples {
+		samples[i] = int32(10000 * math.Sin(float64(i)/10))
+	}
+
+	info := &meta.StreamInfo{BlockSizeMin: blockSize, BlockSizeMax: blockSize, SampleRate: 44100, NChannels: 1, BitsPerSample: 16}
+	out := new(bytes.Buffer)
+	enc, err := flac.NewEncoder(out, info)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := enc.WriteSamples(samples); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := enc.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	r := &countReader{ReadSeeker: bytes.NewReader(out.Bytes())}
+	stream, err := flac.NewSeek(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// only the frame headers of candidate frames are read,
+	// except for the frame containing the sample number
+	const frameSize = 2 * blockSize
+	for _, sampleNum := range []uint64{11*blockSize + 100, 3 * blockSize, 15*blockSize + 1} {
+		r.n = 0
+		first, err := stream.Seek(sampleNum)
+		if err != nil {
+			t.Fatalf("sample %d: unable to seek; %v", sampleNum, err)
+		}
+
+		if want := sampleNum / blockSize * blockSize; first != want {
+			t.Errorf("sample %d: first sample number mismatch; expected %d, got %d", sampleNum, want, first)
+		}
+
+		if r.n > 2*frameSize {
+			t.Errorf("sample %d: read %d bytes while seeking; expected at most %d", sampleNum, r.n, 2*frameSize)
+		}
+	}
+}
+
+// countReader counts the bytes read from an io.ReadSeeker.
+type countReader struct {
+	io.ReadSeeker
+	n int
+}
+
+func (r *countReader) Read(p []byte) (int, error) {
+	n, err := r.ReadSeeker.Read(p)
+	r.n += n
+	return n, err
+}
+
+func TestSeekForward(t *testing.T) {
+	samples, data := encodeNoise(t)
+	funcs := map[string]func(io.Reader) (*flac.Stream, error){
+		"new":   flac.New,
+		"parse": flac.Parse,
+	}
+
+	for name, f := range funcs {
+		stream, err := f(bytes.NewReader(data))
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+
+		buf := make([]int32, 2)
+		for _, sampleNum := range []uint64{3, 4095, 4096, 4097, 12345, 50000, 77777, 99999} {
+			if err := stream.SeekSample(sampleNum); err != nil {
+				t.Fatalf("%s: sample %d: unable to seek; %v", name, sampleNum, err)
+			}
+
+			if _, err := stream.ReadSamples(buf); err != nil {
+				t.Fatalf("%s: sample %d: unable to read samples; %v", name, sampleNum, err)
+			}
+
+			if want := samples[2*sampleNum : 2*sampleNum+2]; !reflect.DeepEqual(buf, want) {
+				t.Errorf("%s: sample %d: sample mismatch; expected %v, got %v", name, sampleNum, want, buf)
+			}
+
+			if sampleNum <= 1000 || sampleNum == 99999 {
+				continue
+			}
+
+			// rejected backward seeks leave the position of ReadSamples unchanged
+			if _, err := stream.Seek(1000); err != flac.ErrNoSeeker {
+				t.Errorf("%s: sample %d: backward seek; expected %v, got %v", name, sampleNum, flac.ErrNoSeeker, err)
+			}
+
+			if _, err := stream.ReadSamples(buf); err != nil {
+				t.Fatalf("%s: sample %d: unable to read samples after backward seek; %v", name, sampleNum, err)
+			}
+
+			if want := samples[2*sampleNum+2 : 2*sampleNum+4]; !reflect.DeepEqual(buf, want) {
+				t.Errorf("%s: sample %d: sample mismatch after backward seek; expected %v, got %v", name, sampleNum+1, want, buf)
+			}
+		}
+
+		// backward seeks are not supported
+		if _, err := stream.Seek(1000); err != flac.ErrNoSeeker {
+			t.Errorf("%s: backward seek; expected %v, got %v", name, flac.ErrNoSeeker, err)
+		}
+	}
+}
+
+func TestResync(t *testing.T) {
+	samples, data := encodeNoise(t)
+
+	// overwrite audio data in the middle of the stream, and
+	// insert garbage containing a false sync code further on
+	corrupt := append([]byte(nil), data[:len(data)/2]...)
+	corrupt = append(corrupt, bytes.Repeat([]byte{0x55}, 100)...)
+	corrupt = append(corrupt, data[len(data)/2+100:3*len(data)/4]...)
+	corrupt = append(corrupt, 0xFF, 0xF8, 0x00, 0x12, 0x34)
+	corrupt = append(corrupt, data[3*len(data)/4:]...)
+
+	funcs := map[string]func(io.Reader) (*flac.Stream, error){
+		"new": flac.New,
+		"newseek": func(r io.Reader) (*flac.Stream, error) {
+			return flac.NewSeek(r.(io.ReadSeeker))
+		},
+	}
+
+	// number of frames preceding the first corrupt frame
+	stream, err := flac.New(bytes.NewReader(corrupt))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var valid int
+	for ; ; valid++ {
+		if _, err := stream.ParseNext(); err != nil {
+			break
+		}
+	}
+
+	// resync mode is enabled from the start, or right before the corrupt frame
+	for name, f := range funcs {
+		for _, before := range []int{0, valid} {
+			stream, err := f(bytes.NewReader(corrupt))
+			if err != nil {
+				t.Fatalf("%s: %v", name, err)
+			}
+
+			var lost, decoded uint64
+			check := func(frame *frame.Frame) {
+				first := frame.SampleNumber()
+				for i := 0; i < int(frame.BlockSize); i++ {
+					for channel, subframe := range frame.Subframes {
+						if got, want := subframe.Samples[i], samples[2*(first+uint64(i))+uint64(channel)]; got != want {
+							t.Fatalf("%s: sample %d of channel %d mismatch; expected %d, got %d", name, first+uint64(i), channel, want, got)
+						}
+					}
+				}
+				decoded += uint64(frame.BlockSize)
+			}
+
+			for i := 0; i < before; i++ {
+				frame, err := stream.ParseNext()
+				if err != nil {
+					t.Fatalf("%s: unable to parse frame; %v", name, err)
+				}
+				check(frame)
+			}
+
+			var losses []flac.SyncLoss
+			stream.EnableResync(func(loss flac.SyncLoss) {
+				losses = append(losses, loss)
+			})
+
+			for {
+				frame, err := stream.ParseNext()
+				if err != nil {
+					if err == io.EOF {
+						break
+					}
+					t.Fatalf("%s: unable to parse frame; %v", name, err)
+				}
+				check(frame)
+			}
+
+			if len(losses) != 2 {
+				t.Fatalf("%s: number of sync losses mismatch; expected 2, got %d", name, len(losses))
+			}
+
+			for _, loss := range losses {
+				if loss.Err == nil || loss.Bytes == 0 {
+					t.Errorf("%s: invalid sync loss %+v", name, loss)
+				}
+				lost += loss.Samples
+			}
+
+			// the false sync code is skipped along with the frame containing it
+			if losses[1].Samples == 0 {
+				t.Errorf("%s: expected samples of corrupt frame to be lost", name)
+			}
+
+			if want := uint64(len(samples) / 2); decoded+lost != want {
+				t.Errorf("%s: %d frames before resync: sample count mismatch; expected %d, got %d decoded and %d lost", name, before, want, decoded, lost)
+			}
+		}
+	}
+}
+
+func TestConceal(t *testing.T) {
+	samples, data := encodeNoise(t)
+
+	// flip bits of audio data in the middle of the stream, and truncate its end
+	corrupt := append([]byte(nil), data[:len(data)-100]...)
+	corrupt[len(data)/2] ^= 0x5A
+
+	for _, mode := range []flac.ConcealMode{flac.ConcealSilence, flac.ConcealFade} {
+		stream, err := flac.New(bytes.NewReader(corrupt))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		concealed := make(map[uint64]flac.Concealment)
+		stream.EnableConcealment(mode, func(c flac.Concealment) {
+			concealed[c.SampleNum] = c
+		})
+
+		var next uint64
+		for {
+			frame, err := stream.ParseNext()
+			if err != nil {
+				if err == io.EOF {
+					break
+				}
+				t.Fatalf("mode %d: unable to parse frame; %v", mode, err)
+			}
+
+			first := frame.SampleNumber()
+			if first != next {
+				t.Fatalf("mode %d: sample number mismatch; expected %d, got %d", mode, next, first)
+			}
+			next += uint64(frame.BlockSize)
+
+			c, ok := concealed[first]
+			if ok && c.Mode == flac.ConcealFade {
+				continue
+			}
+
+			for i := 0; i < int(frame.BlockSize); i++ {
+				for channel, subframe := range frame.Subframes {
+					want := samples[2*(first+uint64(i))+uint64(channel)]
+					if ok {
+						want = 0
+					}
+					if got := subframe.Samples[i]; got != want {
+						t.Fatalf("mode %d: sample %d of channel %d mismatch; expected %d, got %d", mode, first+uint64(i), channel, want, got)
+					}
+				}
+			}
+		}
+
+		if want := uint64(len(samples) / 2); next != want {
+			t.Errorf("mode %d: sample count mismatch; expected %d, got %d", mode, want, next)
+		}
+
+		// the corrupt frame, and the truncated end of the stream
+		if len(concealed) < 2 {
+			t.Fatalf("mode %d: expected at least 2 concealed frames, got %d", mode, len(concealed))
+		}
+
+		var faded bool
+		for _, c := range concealed {
+			if c.Err == nil || c.BlockSize == 0 {
+				t.Errorf("mode %d: invalid concealment %+v", mode, c)
+			}
+			faded = faded || c.Mode == flac.ConcealFade
+		}
+
+		if faded != (mode == flac.ConcealFade) {
+			t.Errorf("mode %d: faded concealment mismatch; expected %v, got %v", mode, mode == flac.ConcealFade, faded)
+		}
+	}
+}
+
+func TestConcealShortFinalFrame(t *testing.T) {
+	// fixed block size stream of a frame and a short final frame,
+	// whose StreamInfo overstates the number of samples
+	const blockSize, nsamples, overstated = 4096, 5096, 6000
+	info := &meta.StreamInfo{BlockSizeMin: blockSize, BlockSizeMax: blockSize, SampleRate: 44100, NChannels: 1, BitsPerSample: 16, NSamples: overstated}
+	out := new(bytes.Buffer)
+	enc, err := flac.NewEncoder(out, info)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	samples := make([]int32, nsamples)
+	for i := range samples {
+		samples[i] = int32(10000 * math.Sin(float64(i)/10))
+	}
+
+	if _, err := enc.WriteSamples(samples); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := enc.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	stream, err := flac.New(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var concealed []flac.Concealment
+	stream.EnableConcealment(flac.ConcealSilence, func(c flac.Concealment) {
+		concealed = append(concealed, c)
+	})
+
+	var next uint64
+	for i := 0; ; i++ {
+		if i == 10 {
+			t.Fatalf("expected end of stream after sample %d", next)
+		}
+
+		frame, err := stream.ParseNext()
+		if err != nil {
+			if err == io.EOF {
+				break
+			}
+			t.Fatalf("unable to parse frame; %v", err)
+		}
+		next += uint64(frame.BlockSize)
+	}
+
+	if next != overstated {
+		t.Errorf("sample count mismatch; expected %d, got %d", overstated, next)
+	}
+
+	// the missing samples following the short final frame
+	want := []flac.Concealment{{SampleNum: nsamples, BlockSize: overstated - nsamples, Mode: flac.ConcealSilence}}
+	if len(concealed) != 1 || concealed[0].Err == nil {
+		t.Fatalf("concealment mismatch; expected %+v, got %+v", want, concealed)
+	}
+
+	if concealed[0].Err = nil; concealed[0] != want[0] {
+		t.Errorf("concealment mismatch; expected %+v, got %+v", want[0], concealed[0])
+	}
+}
+
+func TestMD5Check(t *testing.T) {
+	_, data := encodeNoise(t)
+	golden := []struct {
+		name   string
+		md5sum func(sum [md5.Size]uint8) [md5.Size]uint8
+		seek   bool
+		want   error
+	}{
+		{name: "valid", md5sum: func(sum [md5.Size]uint8) [md5.Size]uint8 { return sum }, want: io.EOF},
+		{name: "mismatch", md5sum: func(sum [md5.Size]uint8) [md5.Size]uint8 { sum[0] ^= 1; return sum }, want: flac.ErrMD5Mismatch},
+		{name: "unset", md5sum: func(sum [md5.Size]uint8) [md5.Size]uint8 { return [md5.Size]uint8{} }, want: io.EOF},
+		{name: "seek", md5sum: func(sum [md5.Size]uint8) [md5.Size]uint8 { sum[0] ^= 1; return sum }, seek: true, want: io.EOF},
+	}
+
+	for _, g := range golden {
+		stream, err := flac.NewSeek(bytes.NewReader(data))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		stream.Info.MD5sum = g.md5sum(stream.Info.MD5sum)
+		stream.EnableMD5Check()
+		if g.seek {
+			if _, err := stream.Seek(50000); err != nil {
+				t.Fatalf("%s: unable to seek; %v", g.name, err)
+			}
+		}
+
+		for err == nil {
+			_, err = stream.ParseNext()
+		}
+
+		if !errors.Is(err, g.want) {
+			t.Errorf("%s: error mismatch; expected %v, got %v", g.name, g.want, err)
+		}
+
+		// the checksum is verified once
+		if _, err := stream.ParseNext(); err != io.EOF {
+			t.Errorf("%s: error mismatch after end of stream; expected %v, got %v", g.name, io.EOF, err)
+		}
+	}
+}
+
+func TestParseNextInto(t *testing.T) {
+	samples, data := encodeNoise(t)
+	samples32, data32 := encodeWideSide(t)
+	golden := []struct {
+		name    string
+		samples []int32
+		data    []byte
+		enable  func(stream *flac.Stream)
+		allocs  bool // frames are copied into the buffers of f
+	}{
+		{name: "default", samples: samples, data: data, enable: func(stream *flac.Stream) {}},
+		{name: "resync", samples: samples, data: data, enable: func(stream *flac.Stream) { stream.EnableResync(nil) }},
+		{name: "md5", samples: samples, data: data, enable: func(stream *flac.Stream) { stream.EnableMD5Check() }},
+		{name: "conceal", samples: samples, data: data, enable: func(stream *flac.Stream) { stream.EnableConcealment(flac.ConcealSilence, nil) }, allocs: true},
+		{name: "32-bit", samples: samples32, data: data32, enable: func(stream *flac.Stream) {}},
+	}
+
+	for _, g := range golden {
+		samples := g.samples
+		stream, err := flac.New(bytes.NewReader(g.data))
+		if err != nil {
+			t.Fatal(err)
+		}
+		g.enable(stream)
+
+		// the buffers of the frame grow to the largest block size of the stream
+		f := new(frame.Frame)
+		f.Subframes = []*frame.Subframe{
+			{Samples: make([]int32, 0, stream.Info.BlockSizeMax)},
+			{Samples: make([]int32, 0, stream.Info.BlockSizeMax)},
+		}
+
+		var next uint64
+		parse := func() {
+			if err := stream.ParseNextInto(f); err != nil {
+				t.Fatalf("%s: unable to parse frame; %v", g.name, err)
+			}
+
+			first := f.SampleNumber()
+			if first != next {
+				t.Fatalf("%s: sample number mismatch; expected %d, got %d", g.name, next, first)
+			}
+			next += uint64(f.BlockSize)
+
+			for i := 0; i < int(f.BlockSize); i++ {
+				for channel, subframe := range f.Subframes {
+					if got, want := subframe.Samples[i], samples[2*(first+uint64(i))+uint64(channel)]; got != want {
+						t.Fatalf("%s: sample %d of channel %d mismatch; expected %d, got %d", g.name, first+uint64(i), channel, want, got)
+					}
+				}
+			}
+		}
+
+		parse()
+		if g.allocs {
+			parse()
+		} else if allocs := testing.AllocsPerRun(5, parse); allocs != 0 {
+			t.Errorf("%s: allocations per frame mismatch; expected 0, got %v", g.name, allocs)
+		}
+	}
+}
@@ -128,12 +128,68 @@ type Frame struct {
 	Subframes []*Subframe
 	// CRC-16 hash sum, calculated by read operations on hr.
 	crc hashutil.Hash16
-	// A bit reader, wrapping read operations to hr.
+	// CRC-8 hash sum of the frame header, calculated by read operations on hr8.
+	crc8 hashutil.Hash8
+	// A bit reader, wrapping read operations to hr8.
 	br *bits.Reader
 	// A CRC-16 hash reader, wrapping read operations to r.
-	hr io.Reader
+	hr hashSource
+	// A CRC-8 hash reader, wrapping read operations to hr.
+	hr8 hashSource
 	// Underlying io.Reader.
 	r io.Reader
+	// Buffer of the CRC-16 checksum, and of the audio samples added to a hash by Hash.
+	buf [4]byte
+}
+
+// hashReader adds the data from all read operations on r to a running hash,
+// unless the hash is nil. Unlike io.TeeReader, it is reused across frames.
+type hashReader struct {
+	r io.Reader
+	h hash.Hash
+}
+
+// Read reads up to len(p) bytes from the underlying reader into p,
+// and adds them to the running hash.
+func (hr *hashReader) Read(p []byte) (n int, err error) {
+	n, err = hr.r.Read(p)
+	if n > 0 && hr.h != nil {
+		hr.h.Write(p[:n])
+	}
+	return n, err
+}
+
+// hashSource is a hashReader of a buffered source of bytes, which adds the
+// bytes read by the bit reader to the running hash when they are discarded.
+type hashSource struct {
+	hashReader
+	// Underlying buffered source; nil if not buffered.
+	src bits.Source
+}
+
+// Peek returns the next n bytes of the source without advancing the reader.
+func (hs *hashSource) Peek(n int) ([]byte, error) {
+	return hs.src.Peek(n)
+}
+
+// Discard skips the next n bytes of the source, and adds them to the running hash.
+func (hs *hashSource) Discard(n int) (int, error) {
+	buf, err := hs.src.Peek(n)
+	if hs.h != nil {
+		hs.h.Write(buf)
+	}
+
+	discarded, derr := hs.src.Discard(len(buf))
+	if err == nil {
+		err = derr
+	}
+
+	return discarded, err
+}
+
+// Buffered returns the number of bytes that can be read from the buffer of the source.
+func (hs *hashSource) Buffered() int {
+	return hs.src.Buffered()
 }
 
 // New creates a new Frame for accessing the audio samples of r.
@@ -142,23 +198,53 @@ type Frame struct {
 //
 // Call Frame.Parse to parse the audio samples of its subframes.
 func New(r io.Reader) (frame *Frame, err error) {
-	// create a new CRC-16 hash reader which adds the
-	// data from all read operations to a running hash
-	crc := crc16.NewIBM()
-	hr := io.TeeReader(r, crc)
-
 	// parse frame header
-	frame = &Frame{crc: crc, hr: hr, r: r}
+	frame = new(Frame)
+	frame.reset(r)
 	err = frame.parseHeader()
 	return frame, err
 }
 
+// reset prepares the frame for parsing the next frame of r,
+// reusing the hash sums, readers and subframes of the frame.
+func (frame *Frame) reset(r io.Reader) {
+	if frame.crc == nil {
+		frame.crc = crc16.NewIBM()
+		frame.crc8 = crc8.NewATM()
+		frame.br = new(bits.Reader)
+	}
+
+	// create CRC-16 and CRC-8 hash readers which add the
+	// data from all read operations to a running hash
+	frame.crc.Reset()
+	frame.crc8.Reset()
+	src, _ := r.(bits.Source)
+	frame.hr = hashSource{hashReader: hashReader{r: r, h: frame.crc}, src: src}
+	if src != nil {
+		// read bits directly from the buffer of r
+		frame.hr8 = hashSource{hashReader: hashReader{r: &frame.hr, h: frame.crc8}, src: &frame.hr}
+		frame.br.ResetSource(&frame.hr8)
+	} else {
+		frame.hr8 = hashSource{hashReader: hashReader{r: &frame.hr.hashReader, h: frame.crc8}}
+		frame.br.Reset(&frame.hr8.hashReader)
+	}
+	frame.r = r
+	frame.Header = Header{}
+}
+
 // Correlate reverts any inter-channel decorrelation between the samples of the subframes.
 // An encoder decorrelates audio samples as follows:
 //
 //	mid = (left + right)/2
 //	side = left - right
+//
+// The side channel of 32-bit audio samples requires 33 bits per sample,
+// which is handled by Correlate when the samples are decoded by Parse.
 func (frame *Frame) Correlate() {
+	if frame.correlateWide() {
+		return
+	}
+
 	switch frame.Channels {
 	case ChannelsLeftSide:
 		// 2 channels: left, side; using inter-channel decorrelation.
@@ -198,6 +284,49 @@ func (frame *Frame) Correlate() {
 	}
 }
 
+// correlateWide reverts the inter-channel decorrelation of subframes
+// with a side channel of more than 32 bits per sample,
+// using 64-bit arithmetic. It reports whether the frame has such a side channel.
+func (frame *Frame) correlateWide() bool {
+	var side *Subframe
+	switch frame.Channels {
+	case ChannelsSideRight:
+		side = frame.Subframes[0]
+	case ChannelsLeftSide, ChannelsMidSide:
+		side = frame.Subframes[1]
+	}
+
+	if side == nil || side.wide == nil {
+		return false
+	}
+
+	switch frame.Channels {
+	case ChannelsLeftSide:
+		left := frame.Subframes[0].Samples
+		for i, s := range side.wide {
+			// right = left - side
+			side.Samples[i] = int32(int64(left[i]) - s)
+		}
+	case ChannelsSideRight:
+		right := frame.Subframes[1].Samples
+		for i, s := range side.wide {
+			// left = right + side
+			side.Samples[i] = int32(int64(right[i]) + s)
+		}
+	case ChannelsMidSide:
+		mid := frame.Subframes[0].Samples
+		for i, s := range side.wide {
+			m := int64(mid[i])*2 | s&1
+			mid[i] = int32((m + s) / 2)
+			side.Samples[i] = int32((m - s) / 2)
+		}
+	}
+
+	// the audio samples of both channels now fit in 32 bits
+	side.wide = nil
+	return true
+}
+
 // Decorrelate performs inter-channel decorrelation between the samples of the subframes.
 // An encoder decorrelates audio samples as follows:
 //
@@ -252,8 +381,13 @@ func (frame *Frame) Decorrelate() {
 // it correlates them.
 func (frame *Frame) Parse() error {
 	var err error
-	frame.Subframes = make([]*Subframe, frame.Channels.Count())
-	for channel := range frame.Subframes {
+	nchannels := frame.Channels.Count()
+	if cap(frame.Subframes) < nchannels {
+		frame.Subframes = make([]*Subframe, nchannels)
+	}
+
+	frame.Subframes = frame.Subframes[:nchannels]
+	for channel, subframe := range frame.Subframes {
 		// side channel requires an extra bit per sample when
 		// using inter-channel decorrelation.
 		bps := uint(frame.BitsPerSample)
@@ -270,7 +404,12 @@ func (frame *Frame) Parse() error {
 			}
 		}
 
-		if frame.Subframes[channel], err = frame.parseSubframe(frame.br, bps); err != nil {
+		if subframe == nil {
+			subframe = new(Subframe)
+			frame.Subframes[channel] = subframe
+		}
+
+		if err = frame.parseSubframe(frame.br, bps, subframe); err != nil {
 			return err
 		}
 	}
@@ -279,11 +418,16 @@ func (frame *Frame) Parse() error {
 	frame.Correlate()
 
 	// 2 bytes: CRC-16 checksum
-	var want uint16
-	if err = binary.Read(frame.r, binary.BigEndian, &want); err != nil {
+	if err = frame.br.Flush(); err != nil {
+		return err
+	}
+
+	if _, err = io.ReadFull(frame.r, frame.buf[:2]); err != nil {
 		return unexpected(err)
 	}
 
+	want := binary.BigEndian.Uint16(frame.buf[:2])
+
 	if got := frame.crc.Sum16(); got != want {
 		return fmt.Errorf("frame.Frame.Parse: CRC-16 checksum mismatch; expected 0x%04X, got 0x%04X", want, got)
 	}
@@ -296,7 +440,7 @@ func (frame *Frame) Parse() error {
 // to verify the integrity of the decoded audio samples.
 // Note: The audio samples of the frame must be decoded before calling Hash.
 func (frame *Frame) Hash(md5sum hash.Hash) {
-	var buf [3]byte
+	buf := &frame.buf
 	// write decoded samples to a running MD5 hash
 	bps := frame.BitsPerSample
 	for i := 0; i < int(frame.BlockSize); i++ {
@@ -314,6 +458,12 @@ func (frame *Frame) Hash(md5sum hash.Hash) {
 				buf[0] = uint8(sample)
 				buf[1] = uint8(sample >> 8)
 				buf[2] = uint8(sample >> 16)
+				md5sum.Write(buf[:3])
+			case 25 <= bps && bps <= 32:
+				buf[0] = uint8(sample)
+				buf[1] = uint8(sample >> 8)
+				buf[2] = uint8(sample >> 16)
+				buf[3] = uint8(sample >> 24)
 				md5sum.Write(buf[:])
 			default:
 				log.Printf("frame.Frame.Hash: support for %d-bit sample size not yet implemented", bps)
@@ -462,7 +612,7 @@ func (frame *Frame) parseBitsPerSample(br *bits.Reader) error {
 	//    100: 16 bits-per-sample.
 	//    101: 20 bits-per-sample.
 	//    110: 24 bits-per-sample.
-	//    111: reserved.
+	//    111: 32 bits-per-sample.
 	switch x {
 	case 0x0:
 		// 000: unknown bits-per-sample; get from StreamInfo
@@ -481,9 +631,11 @@ func (frame *Frame) parseBitsPerSample(br *bits.Reader) error {
 	case 0x6:
 		// 110: 24 bits-per-sample
 		frame.BitsPerSample = 24
+	case 0x7:
+		// 111: 32 bits-per-sample
+		frame.BitsPerSample = 32
 	default:
 		// 011: reserved
-		// 111: reserved
 		return fmt.Errorf("frame.Frame.parseHeader: reserved sample size bit pattern (%03b)", x)
 	}
 
@@ -534,15 +686,7 @@ func (frame *Frame) parseBlockSize(br *bits.Reader, blockSize uint64) error {
 
 // parseHeader reads and parses the header of an audio frame.
 func (frame *Frame) parseHeader() error {
-	// create a new CRC-8 hash reader which adds the
-	// data from all read operations to a running hash.
-	h := crc8.NewATM()
-	hr := io.TeeReader(frame.hr, h)
-
-	// create bit reader
-	br := bits.NewReader(hr)
-	frame.br = br
-
+	br := frame.br
 	// 14 bits: sync-code (11111111111110)
 	x, err := br.Read(14)
 	if err != nil {
@@ -603,7 +747,7 @@ func (frame *Frame) parseHeader() error {
 		return errors.New("frame.Frame.parseHeader: non-zero reserved value")
 	}
 
-	frame.Num, err = utf8.Decode(hr)
+	frame.Num, err = utf8.Decode(br)
 	if err != nil {
 		return unexpected(err)
 	}
@@ -619,12 +763,23 @@ func (frame *Frame) parseHeader() error {
 	}
 
 	// 1 byte: CRC-8 checksum
-	var want uint8
-	if err = binary.Read(frame.hr, binary.BigEndian, &want); err != nil {
+	if err = br.Flush(); err != nil {
+		return err
+	}
+
+	got := frame.crc8.Sum8()
+	want, err := br.ReadByte()
+	if err != nil {
 		return unexpected(err)
 	}
 
-	got := h.Sum8()
+	// the bytes of the frame header are consumed from the underlying reader,
+	// and the CRC-8 hash sum is not needed for the remainder of the frame
+	if err = br.Flush(); err != nil {
+		return err
+	}
+	frame.hr8.h = nil
+
 	if want != got {
 		return fmt.Errorf("frame.Frame.parseHeader: CRC-8 checksum mismatch; expected 0x%02X, got 0x%02X", want, got)
 	}
@@ -647,6 +802,19 @@ func Parse(r io.Reader) (frame *Frame, err error) {
 	return frame, frame.Parse()
 }
 
+// ParseInto reads and parses the header, and the audio samples from each
+// subframe of the next frame of r into frame, as described by Parse.
+// The subframes and buffers of frame, as parsed by previous calls,
+// are reused; their audio samples are overwritten.
+func ParseInto(r io.Reader, frame *Frame) error {
+	frame.reset(r)
+	if err := frame.parseHeader(); err != nil {
+		return err
+	}
+
+	return frame.Parse()
+}
+
 // unexpected returns io.ErrUnexpectedEOF if error is io.EOF,
 // and returns error otherwise.
 func unexpected(err error) error {
@@ -4,9 +4,16 @@ import (
 	"bytes"
 	"crypto/md5"
 	"io"
+	"math"
+	"reflect"
 	"testing"
 
+	"github.com/icza/bitio"
 	"github.com/pchchv/flac"
+	"github.com/pchchv/flac/frame"
+	"github.com/pchchv/flac/internal/bits"
+	"github.com/pchchv/flac/internal/hashutil/crc16"
+	"github.com/pchchv/flac/internal/hashutil/crc8"
 )
 
 var golden = []struct {
@@ -133,6 +140,112 @@ func TestFrameHash(t *testing.T) {
 	}
 }
 
+func TestFrameParseWideSide(t *testing.T) {
+	// 32-bit audio samples of opposite extremes,
+	// whose side channel requires 33 bits per sample
+	const blockSize = 16
+	var left, right []int32
+	for i := int32(0); i < blockSize; i++ {
+		left = append(left, math.MaxInt32-1000*i-i%3)
+		right = append(right, math.MinInt32+1000*i+i%2)
+	}
+
+	tests := []struct {
+		channels frame.Channels
+		// channel assignment bit pattern of the frame header
+		bits uint64
+	}{
+		{channels: frame.ChannelsLeftSide, bits: 0x8},
+		{channels: frame.ChannelsSideRight, bits: 0x9},
+		{channels: frame.ChannelsMidSide, bits: 0xA},
+	}
+
+	for _, test := range tests {
+		// decorrelated audio samples of each channel, and their sample size
+		var channels [2][]int64
+		var bps [2]uint8
+		for i := range left {
+			l, r := int64(left[i]), int64(right[i])
+			switch test.channels {
+			case frame.ChannelsLeftSide:
+				channels[0] = append(channels[0], l)
+				channels[1] = append(channels[1], l-r)
+				bps = [2]uint8{32, 33}
+			case frame.ChannelsSideRight:
+				channels[0] = append(channels[0], l-r)
+				channels[1] = append(channels[1], r)
+				bps = [2]uint8{33, 32}
+			case frame.ChannelsMidSide:
+				channels[0] = append(channels[0], (l+r)>>1)
+				channels[1] = append(channels[1], l-r)
+				bps = [2]uint8{32, 33}
+			}
+		}
+
+		// frame header; fixed block size, frame number 0,
+		// 8-bit block size at end of header, 44.1 kHz and 32 bits per sample
+		header := []byte{0xFF, 0xF8, 0x69, uint8(test.bits<<4 | 0x7<<1), 0x00, blockSize - 1}
+		header = append(header, crc8.ChecksumATM(header))
+
+		buf := new(bytes.Buffer)
+		buf.Write(header)
+		bw := bitio.NewWriter(buf)
+		for i, samples := range channels {
+			if bps[i] == 32 {
+				// verbatim subframe
+				bw.WriteBits(0x02, 8)
+				for _, sample := range samples {
+					bw.WriteBits(uint64(sample), bps[i])
+				}
+				continue
+			}
+
+			// fixed subframe of order 2 with Rice coded residuals
+			bw.WriteBits(0x14, 8)
+			for _, sample := range samples[:2] {
+				bw.WriteBits(uint64(sample)&(1<<bps[i]-1), bps[i])
+			}
+			// rice1, partition order 0 and Rice parameter 0
+			bw.WriteBits(0x0, 2)
+			bw.WriteBits(0x0, 4)
+			bw.WriteBits(0x0, 4)
+			for j := 2; j < len(samples); j++ {
+				residual := samples[j] - 2*samples[j-1] + samples[j-2]
+				// unary coded folded residual
+				for k := bits.EncodeZigZag(int32(residual)); k > 0; k-- {
+					bw.WriteBool(false)
+				}
+				bw.WriteBool(true)
+			}
+		}
+
+		if _, err := bw.Align(); err != nil {
+			t.Fatal(err)
+		}
+
+		crc := crc16.ChecksumIBM(buf.Bytes())
+		buf.Write([]byte{uint8(crc >> 8), uint8(crc)})
+
+		f, err := frame.Parse(buf)
+		if err != nil {
+			t.Errorf("channels=%d: unable to parse frame; %v", test.channels, err)
+			continue
+		}
+
+		if f.BitsPerSample != 32 {
+			t.Errorf("channels=%d: sample size mismatch; expected 32, got %d", test.channels, f.BitsPerSample)
+		}
+
+		if !reflect.DeepEqual(f.Subframes[0].Samples, left) {
+			t.Errorf("channels=%d: left channel mismatch; expected %v, got %v", test.channels, left, f.Subframes[0].Samples)
+		}
+
+		if !reflect.DeepEqual(f.Subframes[1].Samples, right) {
+			t.Errorf("channels=%d: right channel mismatch; expected %v, got %v", test.channels, right, f.Subframes[1].Samples)
+		}
+	}
+}
+
 func BenchmarkFrameHash(b *testing.B) {
 	// File 151185.flac is a 119.5 MB public domain FLAC file used for testing the flac library.
 	// Due to its size, it is not included in the repository,
@@ -168,6 +281,8 @@ func BenchmarkFrameHash(b *testing.B) {
 }
 
 func BenchmarkFrameParse(b *testing.B) {
+	b.ReportAllocs()
+	f := new(frame.Frame)
 	for i := 0; i < b.N; i++ {
 		stream, err := flac.Open("../testdata/benchmark/151185.flac")
 		if err != nil {
@@ -175,7 +290,7 @@ func BenchmarkFrameParse(b *testing.B) {
 		}
 
 		for {
-			_, err := stream.ParseNext()
+			err := stream.ParseNextInto(f)
 			if err != nil {
 				if err == io.EOF {
 					break
@@ -122,6 +122,16 @@ type Subframe struct {
 	Samples []int32
 	// Number of audio samples in the subframe.
 	NSamples int
+	// Audio samples of a side channel of more than 32 bits per sample,
+	// as used by the inter-channel decorrelation of 32-bit audio samples;
+	// nil otherwise. Samples holds the audio samples truncated to 32 bits,
+	// until the channels are correlated.
+	wide []int64
+	// Buffers of the predictor coefficients, Rice-coding subframe fields and
+	// wide audio samples, reused across frames by ParseInto.
+	coeffs  []int32
+	rice    RiceSubframe
+	wideBuf []int64
 }
 
 // parseHeader reads and parses the header of a subframe.
@@ -198,20 +208,42 @@ func (subframe *Subframe) parseHeader(br *bits.Reader) error {
 	return nil
 }
 
+// reset prepares the subframe for decoding n audio samples,
+// reusing the buffers of the subframe.
+func (subframe *Subframe) reset(n int, wide bool) {
+	samples := subframe.Samples[:0]
+	if cap(samples) < n {
+		samples = make([]int32, 0, n)
+	}
+
+	*subframe = Subframe{
+		Samples:  samples,
+		NSamples: n,
+		coeffs:   subframe.coeffs,
+		rice:     RiceSubframe{Partitions: subframe.rice.Partitions},
+		wideBuf:  subframe.wideBuf,
+	}
+
+	if wide {
+		if cap(subframe.wideBuf) < n {
+			subframe.wideBuf = make([]int64, 0, n)
+		}
+		subframe.wide = subframe.wideBuf[:0]
+	}
+}
+
 // parseSubframe reads and parses the header,
-// and the audio samples of a subframe.
-func (frame *Frame) parseSubframe(br *bits.Reader, bps uint) (subframe *Subframe, err error) {
+// and the audio samples of a subframe into subframe.
+func (frame *Frame) parseSubframe(br *bits.Reader, bps uint, subframe *Subframe) (err error) {
+	subframe.reset(int(frame.BlockSize), bps > 32)
 	// parse subframe header
-	subframe = new(Subframe)
 	if err = subframe.parseHeader(br); err != nil {
-		return subframe, err
+		return err
 	}
 
 	// adjust bps of subframe for wasted bits-per-sample
 	bps -= subframe.Wasted
 	// decode subframe audio samples
-	subframe.NSamples = int(frame.BlockSize)
-	subframe.Samples = make([]int32, 0, subframe.NSamples)
 	switch subframe.Pred {
 	case PredConstant:
 		err = subframe.decodeConstant(br, bps)
@@ -228,7 +260,11 @@ func (frame *Frame) parseSubframe(br *bits.Reader, bps uint) (subframe *Subframe
 		subframe.Samples[i] = sample << subframe.Wasted
 	}
 
-	return subframe, err
+	for i, sample := range subframe.wide {
+		subframe.wide[i] = sample << subframe.Wasted
+	}
+
+	return err
 }
 
 // decodeConstant reads an unencoded audio sample of the subframe.
@@ -242,9 +278,8 @@ func (subframe *Subframe) decodeConstant(br *bits.Reader, bps uint) error {
 	}
 
 	// Each sample of the subframe has the same constant value.
-	sample := signExtend(x, bps)
 	for i := 0; i < subframe.NSamples; i++ {
-		subframe.Samples = append(subframe.Samples, sample)
+		subframe.appendSample(x, bps)
 	}
 
 	return nil
@@ -260,32 +295,23 @@ func (subframe *Subframe) decodeVerbatim(br *bits.Reader, bps uint) error {
 			return unexpected(err)
 		}
 
-		sample := signExtend(x, bps)
-		subframe.Samples = append(subframe.Samples, sample)
+		subframe.appendSample(x, bps)
 	}
 
 	return nil
 }
 
-// decodeRiceResidual decodes and returns
-// a Rice encoded residual (error signal).
-func (subframe *Subframe) decodeRiceResidual(br *bits.Reader, k uint) (int32, error) {
-	// Read unary encoded most significant bits.
-	high, err := br.ReadUnary()
-	if err != nil {
-		return 0, unexpected(err)
-	}
-
-	// Read binary encoded least significant bits.
-	low, err := br.Read(k)
-	if err != nil {
-		return 0, unexpected(err)
+// appendSample sign extends the unencoded audio sample x of n bits,
+// and appends it to the audio samples of the subframe.
+func (subframe *Subframe) appendSample(x uint64, n uint) {
+	if subframe.wide == nil {
+		subframe.Samples = append(subframe.Samples, signExtend(x, n))
+		return
 	}
-	folded := uint32(high<<k | low)
 
-	// ZigZag decode.
-	residual := bits.DecodeZigZag(folded)
-	return residual, nil
+	sample := bits.IntN(x, n)
+	subframe.wide = append(subframe.wide, sample)
+	subframe.Samples = append(subframe.Samples, int32(sample))
 }
 
 // decodeRicePart decodes a Rice partition of encoded residuals from the subframe,
@@ -298,17 +324,22 @@ func (subframe *Subframe) decodeRicePart(br *bits.Reader, paramSize uint) error
 	}
 
 	partOrder := int(x)
-	riceSubframe := &RiceSubframe{
-		PartOrder: partOrder,
-	}
+	riceSubframe := &subframe.rice
+	riceSubframe.PartOrder = partOrder
 	subframe.RiceSubframe = riceSubframe
 
 	// parse Rice partitions; in total 2^partOrder partitions.
 	nparts := 1 << partOrder
-	partitions := make([]RicePartition, nparts)
+	partitions := riceSubframe.Partitions
+	if cap(partitions) < nparts {
+		partitions = make([]RicePartition, nparts)
+	}
+
+	partitions = partitions[:nparts]
 	riceSubframe.Partitions = partitions
 	for i := 0; i < nparts; i++ {
 		partition := &partitions[i]
+		*partition = RicePartition{}
 		// (4 or 5) bits: Rice parameter.
 		x, err = br.Read(paramSize)
 		if err != nil {
@@ -354,12 +385,14 @@ func (subframe *Subframe) decodeRicePart(br *bits.Reader, paramSize uint) error
 		}
 
 		// decode the Rice encoded residuals of the partition.
-		for j := 0; j < nsamples; j++ {
-			residual, err := subframe.decodeRiceResidual(br, param)
-			if err != nil {
-				return err
-			}
-			subframe.Samples = append(subframe.Samples, residual)
+		start := len(subframe.Samples)
+		if nsamples < 0 || start+nsamples > subframe.NSamples {
+			return fmt.Errorf("frame.Subframe.decodeRicePart: invalid number of residuals (%d) in Rice partition %d", nsamples, i)
+		}
+
+		subframe.Samples = subframe.Samples[:start+nsamples]
+		if err := br.ReadRice(param, subframe.Samples[start:]); err != nil {
+			return unexpected(err)
 		}
 	}
 
@@ -409,6 +442,21 @@ func (subframe *Subframe) decodeLPC(coeffs []int32, shift int32) error {
 		return fmt.Errorf("frame.Subframe.decodeLPC: subframe sample count mismatch; expected %d, got %d", subframe.NSamples, len(subframe.Samples))
 	}
 
+	if subframe.wide != nil {
+		// predict the audio samples of a side channel of more than 32 bits per sample
+		// from the preceding audio samples before truncation to 32 bits
+		for i := subframe.Order; i < subframe.NSamples; i++ {
+			var sample int64
+			for j, c := range coeffs {
+				sample += int64(c) * subframe.wide[i-j-1]
+			}
+			x := int64(subframe.Samples[i]) + sample>>uint(shift)
+			subframe.wide = append(subframe.wide, x)
+			subframe.Samples[i] = int32(x)
+		}
+		return nil
+	}
+
 	for i := subframe.Order; i < subframe.NSamples; i++ {
 		var sample int64
 		for j, c := range coeffs {
@@ -430,8 +478,7 @@ func (subframe *Subframe) decodeFixed(br *bits.Reader, bps uint) error {
 		if err != nil {
 			return unexpected(err)
 		}
-		sample := signExtend(x, bps)
-		subframe.Samples = append(subframe.Samples, sample)
+		subframe.appendSample(x, bps)
 	}
 
 	// decode subframe residuals
@@ -456,8 +503,7 @@ func (subframe *Subframe) decodeFIR(br *bits.Reader, bps uint) error {
 		if err != nil {
 			return unexpected(err)
 		}
-		sample := signExtend(x, bps)
-		subframe.Samples = append(subframe.Samples, sample)
+		subframe.appendSample(x, bps)
 	}
 
 	// 4 bits: (coefficients' precision in bits) - 1
@@ -480,7 +526,11 @@ func (subframe *Subframe) decodeFIR(br *bits.Reader, bps uint) error {
 	subframe.CoeffShift = shift
 
 	// parse coefficients
-	coeffs := make([]int32, subframe.Order)
+	if cap(subframe.coeffs) < subframe.Order {
+		subframe.coeffs = make([]int32, subframe.Order)
+	}
+
+	coeffs := subframe.coeffs[:subframe.Order]
 	for i := range coeffs {
 		// (prec) bits: Predictor coefficient
 		if x, err = br.Read(prec); err != nil {
@@ -2,26 +2,58 @@
 package bits
 
 import (
+	"encoding/binary"
 	"fmt"
 	"io"
 )
 
+// Source is a buffered source of bytes, such as bufio.Reader.
+// A Reader reset by ResetSource reads bits directly from the buffer
+// of a Source, and discards the bytes read by Flush or when refilling its cache.
+type Source interface {
+	io.Reader
+	// Peek returns the next n bytes without advancing the reader.
+	Peek(n int) ([]byte, error)
+	// Discard skips the next n bytes.
+	Discard(n int) (discarded int, err error)
+	// Buffered returns the number of bytes that can be read from the buffer.
+	Buffered() int
+}
+
 // Reader handles bit reading operations.
-// It buffers bits up to the next byte boundary.
+// Bits are buffered in a 64-bit cache. If reset by ResetSource, the cache
+// is refilled from the buffer of a Source; otherwise bytes are read from
+// the underlying reader up to the next byte boundary of a read operation,
+// such that no bytes are read beyond the bits read.
 type Reader struct {
-	r   io.Reader // underlying reader
-	buf [8]uint8  // temporary read buffer
-	x   uint8     // between 0 and 7 buffered bits since previous read operations
-	n   uint      // number of buffered bits in x
+	r     io.Reader // underlying reader
+	src   Source    // underlying reader if buffered; or nil
+	buf   [8]uint8  // temporary read buffer
+	win   []byte    // bytes peeked from src, loaded into cache up to off
+	off   int       // offset in win of the next byte to load into cache
+	cache uint64    // buffered bits, left-aligned
+	n     uint      // number of buffered bits in cache
 }
 
 // NewReader returns a new Reader that reads bits from r.
 func NewReader(r io.Reader) *Reader {
-	return &Reader{r: r}
+	br := new(Reader)
+	br.Reset(r)
+	return br
+}
+
+// Reset discards any buffered bits, and resets br to read from r.
+func (br *Reader) Reset(r io.Reader) {
+	*br = Reader{r: r}
+}
+
+// ResetSource discards any buffered bits, and resets br to read from
+// the buffer of src. Bytes read are consumed from src by Flush.
+func (br *Reader) ResetSource(src Source) {
+	*br = Reader{r: src, src: src}
 }
 
 // Read reads and returns the next n bits, at most 64.
-// It buffers bits up to the next byte boundary.
 func (br *Reader) Read(n uint) (x uint64, err error) {
 	if n == 0 {
 		return 0, nil
@@ -31,53 +63,134 @@ func (br *Reader) Read(n uint) (x uint64, err error) {
 		return 0, fmt.Errorf("bit.Reader.Read: invalid number of bits; n (%d) exceeds 64", n)
 	}
 
-	// Read buffered bits.
-	if br.n > 0 {
-		switch {
-		case br.n == n:
-			br.n = 0
-			return uint64(br.x), nil
-		case br.n > n:
-			br.n -= n
-			mask := ^uint8(0) << br.n
-			x = uint64(br.x&mask) >> br.n
-			br.x &^= mask
-			return x, nil
+	if br.n < n {
+		if n > 56 {
+			// the cache holds at least 57 bits once filled; read in two parts
+			hi, err := br.Read(n - 32)
+			if err != nil {
+				return 0, err
+			}
+
+			lo, err := br.Read(32)
+			if err != nil {
+				return 0, unexpected(err)
+			}
+
+			return hi<<32 | lo, nil
 		}
 
-		n -= br.n
-		x = uint64(br.x)
-		br.n = 0
+		if err := br.fill(n); err != nil {
+			return 0, err
+		}
 	}
 
-	// Fill the temporary buffer.
-	bytes := n / 8
-	bits := n % 8
-	if bits > 0 {
-		bytes++
+	x = br.cache >> (64 - n)
+	br.cache <<= n
+	br.n -= n
+	return x, nil
+}
+
+// ReadByte reads and returns the next 8 bits.
+func (br *Reader) ReadByte() (byte, error) {
+	x, err := br.Read(8)
+	return byte(x), err
+}
+
+// Flush discards the bytes read from the underlying Source, such that they
+// are consumed from its buffer; bits of a partially read byte remain buffered.
+// Flush must be called before reading from the underlying reader directly.
+func (br *Reader) Flush() error {
+	if br.src == nil || br.win == nil {
+		return nil
 	}
 
-	if _, err = io.ReadFull(br.r, br.buf[:bytes]); err != nil {
-		return 0, err
+	// whole bytes of the cache are read again from the Source
+	consumed := br.off - int(br.n/8)
+	br.n %= 8
+	br.cache &^= ^uint64(0) >> br.n
+	br.win, br.off = nil, 0
+	_, err := br.src.Discard(consumed)
+	return err
+}
+
+// fill fills the cache with at least n bits, where n is at most 56.
+// It returns io.EOF if no bits were available,
+// and io.ErrUnexpectedEOF if fewer than n bits were available.
+func (br *Reader) fill(n uint) error {
+	if br.src == nil {
+		// read up to the next byte boundary of n bits
+		bytes := int(n-br.n+7) / 8
+		if _, err := io.ReadFull(br.r, br.buf[:bytes]); err != nil {
+			return err
+		}
+
+		for _, b := range br.buf[:bytes] {
+			br.cache |= uint64(b) << (56 - br.n)
+			br.n += 8
+		}
+		return nil
 	}
 
-	// Read bits from the temporary buffer.
-	for _, b := range br.buf[:bytes-1] {
-		x <<= 8
-		x |= uint64(b)
+	var loaded bool
+	for {
+		if br.load() {
+			loaded = true
+		}
+
+		if br.n >= n {
+			return nil
+		}
+
+		// refill the window with at least the bytes missing from n bits;
+		// whole bytes of the cache are peeked again
+		reloaded := int(br.n / 8)
+		if err := br.Flush(); err != nil {
+			return err
+		}
+
+		missing := int(n-br.n+7) / 8
+		size := br.src.Buffered()
+		if size < missing {
+			size = missing
+		}
+
+		win, err := br.src.Peek(size)
+		if len(win) < missing {
+			if err == nil || err == io.EOF && (loaded || len(win) > reloaded) {
+				err = io.ErrUnexpectedEOF
+			}
+			return err
+		}
+		br.win = win
 	}
+}
 
-	b := br.buf[bytes-1]
-	if bits > 0 {
-		x <<= bits
-		br.n = 8 - bits
-		mask := ^uint8(0) << br.n
-		x |= uint64(b&mask) >> br.n
-		br.x = b & ^mask
-	} else {
-		x <<= 8
-		x |= uint64(b)
+// load loads bytes of the window into the cache, until the cache holds more
+// than 56 bits or the window is exhausted. It reports whether bytes were loaded.
+func (br *Reader) load() bool {
+	if rest := br.win[br.off:]; len(rest) >= 8 {
+		// the bits following the cache are loaded again by subsequent calls
+		br.cache |= binary.BigEndian.Uint64(rest) >> br.n
+		bytes := (63 - br.n) / 8
+		br.off += int(bytes)
+		br.n += bytes * 8
+		return bytes > 0
 	}
 
-	return x, nil
+	start := br.off
+	for ; br.n <= 56 && br.off < len(br.win); br.off++ {
+		br.cache |= uint64(br.win[br.off]) << (56 - br.n)
+		br.n += 8
+	}
+
+	return br.off > start
+}
+
+// unexpected returns io.ErrUnexpectedEOF if err is io.EOF,
+// and returns err otherwise.
+func unexpected(err error) error {
+	if err == io.EOF {
+		return io.ErrUnexpectedEOF
+	}
+	return err
 }
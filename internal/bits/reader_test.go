@@ -1,6 +1,7 @@
 package bits
 
 import (
+	"bufio"
 	"bytes"
 	"io"
 	"math/rand"
@@ -620,6 +621,81 @@ func TestRead(t *testing.T) {
 	}
 }
 
+func TestReadSource(t *testing.T) {
+	data := make([]byte, 4096)
+	rand.Read(data)
+
+	// a small buffer refills the cache across the end of the buffer
+	r := NewReader(bytes.NewReader(data))
+	src := bufio.NewReaderSize(bytes.NewReader(data), 16)
+	sr := new(Reader)
+	sr.ResetSource(src)
+	for read := uint(0); read < 8*uint(len(data)); {
+		n := uint(1 + rand.Intn(64))
+		if rest := 8*uint(len(data)) - read; n > rest {
+			n = rest
+		}
+
+		want, err := r.Read(n)
+		if err != nil {
+			t.Fatalf("unable to read %d bits at bit %d; %v", n, read, err)
+		}
+
+		got, err := sr.Read(n)
+		if err != nil {
+			t.Fatalf("unable to read %d bits at bit %d from source; %v", n, read, err)
+		}
+
+		if got != want {
+			t.Fatalf("%d bits at bit %d mismatch; expected %x, got %x", n, read, want, got)
+		}
+		read += n
+
+		// bytes read are consumed from the source by Flush
+		if rand.Intn(8) == 0 && read%8 == 0 && read < 8*uint(len(data)) {
+			if err := sr.Flush(); err != nil {
+				t.Fatal(err)
+			}
+
+			b, err := src.Peek(1)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if b[0] != data[read/8] {
+				t.Fatalf("byte %d after flush mismatch; expected %x, got %x", read/8, data[read/8], b[0])
+			}
+		}
+	}
+
+	if _, err := sr.Read(1); err != io.EOF {
+		t.Errorf("error mismatch at end of source; expected %v, got %v", io.EOF, err)
+	}
+}
+
+func TestReadSourceEOF(t *testing.T) {
+	tests := []struct {
+		data []byte
+		n    uint
+		err  error
+	}{
+		{[]byte{0xFF}, 8, nil},
+		{[]byte{0xFF}, 9, io.ErrUnexpectedEOF},
+		{[]byte{}, 1, io.EOF},
+		{[]byte{0xFF, 0xFF}, 17, io.ErrUnexpectedEOF},
+		{[]byte{0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF}, 64, nil},
+		{[]byte{0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF}, 64, io.ErrUnexpectedEOF},
+	}
+
+	for i, test := range tests {
+		r := new(Reader)
+		r.ResetSource(bufio.NewReader(bytes.NewReader(test.data)))
+		if _, err := r.Read(test.n); err != test.err {
+			t.Errorf("i=%d; Reading %d from %v, expected err=%s, got err=%s", i, test.n, test.data, test.err, err)
+		}
+	}
+}
+
 func BenchmarkReadAlign1(b *testing.B) {
 	benchmarkReads(b, 64, 1)
 }
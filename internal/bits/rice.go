@@ -0,0 +1,37 @@
+package bits
+
+import mathbits "math/bits"
+
+// ReadRice decodes len(dst) Rice coded residuals with the Rice parameter k into
+// dst. Each residual is coded as the unary coded most significant bits, followed
+// by the k least significant bits of its ZigZag encoded value.
+func (br *Reader) ReadRice(k uint, dst []int32) error {
+	for i := range dst {
+		if br.n < 32 && br.win != nil {
+			br.load()
+		}
+
+		// decode residuals buffered in the cache
+		if z := uint(mathbits.LeadingZeros64(br.cache)); z+1+k <= br.n {
+			cache := br.cache << (z + 1)
+			low := cache >> (64 - k)
+			br.cache = cache << k
+			br.n -= z + 1 + k
+			dst[i] = DecodeZigZag(uint32(uint64(z)<<k | low))
+			continue
+		}
+
+		high, err := br.ReadUnary()
+		if err != nil {
+			return err
+		}
+
+		low, err := br.Read(k)
+		if err != nil {
+			return unexpected(err)
+		}
+		dst[i] = DecodeZigZag(uint32(high<<k | low))
+	}
+
+	return nil
+}
@@ -0,0 +1,59 @@
+package bits_test
+
+import (
+	"bufio"
+	"bytes"
+	"math/rand"
+	"testing"
+
+	"github.com/icza/bitio"
+	"github.com/pchchv/flac/internal/bits"
+)
+
+func TestReadRice(t *testing.T) {
+	for _, k := range []uint{0, 1, 4, 13, 30} {
+		want := make([]int32, 1000)
+		buf := &bytes.Buffer{}
+		bw := bitio.NewWriter(buf)
+		for i := range want {
+			// small residuals, and some with long unary coded quotients
+			x := rand.Int31n(1<<k + 100)
+			if i%50 == 0 {
+				x = rand.Int31n(1<<k*40 + 40)
+			}
+			if i%2 == 1 {
+				x = -x
+			}
+			want[i] = x
+
+			folded := uint64(bits.EncodeZigZag(x))
+			if err := bits.WriteUnary(bw, folded>>k); err != nil {
+				t.Fatalf("unable to write unary; %v", err)
+			}
+
+			if err := bw.WriteBits(folded&(1<<k-1), uint8(k)); err != nil {
+				t.Fatalf("unable to write bits; %v", err)
+			}
+		}
+
+		if err := bw.Close(); err != nil {
+			t.Fatalf("unable to close (flush) the bit buffer; %v", err)
+		}
+
+		r := bits.NewReader(bytes.NewReader(buf.Bytes()))
+		sr := new(bits.Reader)
+		sr.ResetSource(bufio.NewReaderSize(bytes.NewReader(buf.Bytes()), 16))
+		for _, br := range []*bits.Reader{r, sr} {
+			got := make([]int32, len(want))
+			if err := br.ReadRice(k, got); err != nil {
+				t.Fatalf("k=%d; unable to read Rice coded residuals; %v", k, err)
+			}
+
+			for i := range want {
+				if got[i] != want[i] {
+					t.Fatalf("k=%d; residual %d mismatch; expected %d, got %d", k, i, want[i], got[i])
+				}
+			}
+		}
+	}
+}
@@ -1,6 +1,10 @@
 package bits
 
-import "github.com/icza/bitio"
+import (
+	mathbits "math/bits"
+
+	"github.com/icza/bitio"
+)
 
 // ReadUnary decodes and returns an unary coded integer,
 // whose value is represented by the number of leading zeros before a one.
@@ -15,18 +19,19 @@ import "github.com/icza/bitio"
 //	0000001 => 6
 func (br *Reader) ReadUnary() (x uint64, err error) {
 	for {
-		bit, err := br.Read(1)
-		if err != nil {
-			return 0, err
+		// count the leading zeros of the buffered bits
+		if z := uint(mathbits.LeadingZeros64(br.cache)); z < br.n {
+			br.cache <<= z + 1
+			br.n -= z + 1
+			return x + uint64(z), nil
 		}
 
-		if bit == 1 {
-			break
+		x += uint64(br.n)
+		br.cache, br.n = 0, 0
+		if err := br.fill(1); err != nil {
+			return 0, err
 		}
-		x++
 	}
-
-	return x, nil
 }
 
 // WriteUnary encodes x as an unary coded integer,
@@ -10,7 +10,13 @@ const (
 	minReadBufferSize = 16
 )
 
-var errNegativeRead = errors.New("bufseekio: reader returned negative count from Read")
+var (
+	errNegativeRead = errors.New("bufseekio: reader returned negative count from Read")
+	// ErrBufferFull is returned by Peek if n is larger than the buffer size.
+	ErrBufferFull = errors.New("bufseekio: buffer full")
+	// ErrNegativeCount is returned by Peek and Discard for negative counts.
+	ErrNegativeCount = errors.New("bufseekio: negative count")
+)
 
 // ReadSeeker implements buffering for an io.ReadSeeker object.
 // ReadSeeker is based on bufio.Reader with
@@ -77,7 +83,9 @@ func (b *ReadSeeker) Read(p []byte) (int, error) {
 				panic(errNegativeRead)
 			}
 
-			b.pos += int64(n)
+			// the buffer is empty at the new read position
+			b.pos += int64(b.r) + int64(n)
+			b.r, b.w = 0, 0
 			return n, b.readErr()
 		}
 
@@ -128,6 +136,72 @@ func (b *ReadSeeker) Seek(offset int64, whence int) (int64, error) {
 	return b.seek(abs, io.SeekStart)
 }
 
+// Peek returns the next n bytes without advancing the reader.
+// If Peek returns fewer than n bytes, it also returns an error explaining
+// why the read is short; ErrBufferFull if n is larger than the buffer size.
+func (b *ReadSeeker) Peek(n int) ([]byte, error) {
+	if n < 0 {
+		return nil, ErrNegativeCount
+	}
+
+	for b.buffered() < n && b.buffered() < len(b.buf) && b.err == nil {
+		b.fill()
+	}
+
+	var err error
+	if avail := b.buffered(); avail < n {
+		n = avail
+		if err = b.readErr(); err == nil {
+			err = ErrBufferFull
+		}
+	}
+
+	return b.buf[b.r : b.r+n], err
+}
+
+// Discard skips the next n bytes, returning the number of bytes discarded.
+// Bytes beyond the buffer are skipped by seeking the underlying reader.
+func (b *ReadSeeker) Discard(n int) (int, error) {
+	if n < 0 {
+		return 0, ErrNegativeCount
+	}
+
+	if n <= b.buffered() {
+		b.r += n
+		return n, nil
+	}
+
+	if _, err := b.Seek(int64(n), io.SeekCurrent); err != nil {
+		return 0, err
+	}
+
+	return n, nil
+}
+
+// Buffered returns the number of bytes that can be read from the current buffer.
+func (b *ReadSeeker) Buffered() int {
+	return b.buffered()
+}
+
+// fill reads a new chunk into the buffer,
+// after sliding existing data to the beginning of the buffer.
+func (b *ReadSeeker) fill() {
+	if b.r > 0 {
+		copy(b.buf, b.buf[b.r:b.w])
+		b.pos += int64(b.r)
+		b.w -= b.r
+		b.r = 0
+	}
+
+	n, err := b.rd.Read(b.buf[b.w:])
+	if n < 0 {
+		panic(errNegativeRead)
+	}
+
+	b.w += n
+	b.err = err
+}
+
 // buffered returns the number of bytes that can
 // be read from the current buffer.
 func (b *ReadSeeker) buffered() int {
@@ -153,6 +227,12 @@ func (b *ReadSeeker) reset(buf []byte, r io.ReadSeeker) {
 }
 
 func (b *ReadSeeker) seek(offset int64, whence int) (int64, error) {
-	b.r, b.w = 0, 0
-	return b.rd.Seek(offset, whence)
+	// the buffer is empty at the new absolute position
+	pos, err := b.rd.Seek(offset, whence)
+	if err != nil {
+		return pos, err
+	}
+
+	b.pos, b.r, b.w, b.err = pos, 0, 0, nil
+	return pos, nil
 }
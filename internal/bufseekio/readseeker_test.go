@@ -289,3 +289,59 @@ func TestReadSeeker_Seek(t *testing.T) {
 		t.Fatalf("want n read %d got %d, want buffer %v got %v, err=%v", 5, n, []byte{10, 11, 12, 13, 14}, got, err)
 	}
 }
+
+func TestReadSeeker_PeekDiscard(t *testing.T) {
+	data := make([]byte, 100)
+	for i := range data {
+		data[i] = byte(i)
+	}
+
+	rs := NewReadSeekerSize(bytes.NewReader(data), 20)
+	if len(rs.buf) != 20 {
+		t.Fatal("the buffer size was changed and the validity of this test has become unknown")
+	}
+
+	got := make([]byte, 5)
+	if n, err := rs.Read(got); err != nil || n != 5 {
+		t.Fatalf("want n read %d got %d, err=%v", 5, n, err)
+	}
+
+	// peek beyond the end of the buffered data slides the buffer
+	if p, err := rs.Peek(20); err != nil || !reflect.DeepEqual(p, data[5:25]) {
+		t.Fatalf("want peek %v got %v, err=%v", data[5:25], p, err)
+	}
+
+	if _, err := rs.Peek(21); err != ErrBufferFull {
+		t.Fatalf("want error %v got %v", ErrBufferFull, err)
+	}
+
+	if n, err := rs.Discard(10); err != nil || n != 10 {
+		t.Fatalf("want n discarded %d got %d, err=%v", 10, n, err)
+	}
+
+	if n := rs.Buffered(); n != 10 {
+		t.Fatalf("want n buffered %d got %d", 10, n)
+	}
+
+	if p, err := rs.Seek(0, io.SeekCurrent); err != nil || p != 15 {
+		t.Fatalf("want %d got %d, err=%v", 15, p, err)
+	}
+
+	// discard beyond the buffered data seeks the underlying reader
+	if n, err := rs.Discard(50); err != nil || n != 50 {
+		t.Fatalf("want n discarded %d got %d, err=%v", 50, n, err)
+	}
+
+	if p, err := rs.Peek(5); err != nil || !reflect.DeepEqual(p, data[65:70]) {
+		t.Fatalf("want peek %v got %v, err=%v", data[65:70], p, err)
+	}
+
+	// peek at the end of the data
+	if _, err := rs.Seek(95, io.SeekStart); err != nil {
+		t.Fatal(err)
+	}
+
+	if p, err := rs.Peek(10); err != io.EOF || !reflect.DeepEqual(p, data[95:]) {
+		t.Fatalf("want peek %v got %v, err=%v", data[95:], p, err)
+	}
+}
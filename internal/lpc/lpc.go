@@ -0,0 +1,138 @@
+// Package lpc implements linear predictive coding analysis of audio samples.
+package lpc
+
+import (
+	"errors"
+	"math"
+)
+
+const (
+	// MaxOrder is the highest LPC prediction order supported by FLAC.
+	MaxOrder = 32
+	// MaxShift is the largest quantized coefficient shift supported by the decoder.
+	MaxShift = 15
+)
+
+// ErrZeroCoeffs is returned by Quantize if all coefficients are zero.
+var ErrZeroCoeffs = errors.New("lpc.Quantize: all coefficients are zero")
+
+// Autocorrelation returns the autocorrelation of x for lags 0 through maxLag.
+func Autocorrelation(x []float64, maxLag int) []float64 {
+	autoc := make([]float64, maxLag+1)
+	for lag := range autoc {
+		var sum float64
+		for i := lag; i < len(x); i++ {
+			sum += x[i] * x[i-lag]
+		}
+		autoc[lag] = sum
+	}
+
+	return autoc
+}
+
+// Coefficients computes the LPC coefficients of every prediction order,
+// from 1 through maxOrder, using the Levinson-Durbin recursion on
+// the given autocorrelation (of at least maxOrder+1 lags).
+// The coefficients of order i+1 are stored in coeffs[i],
+// and the corresponding prediction error in errs[i].
+// Fewer orders are returned if the prediction error reaches zero.
+//
+// Coefficients are oriented such that the sample x[n] is predicted as
+//
+//	x[n] = coeffs[order-1][0]*x[n-1] + coeffs[order-1][1]*x[n-2] + ...
+func Coefficients(autoc []float64, maxOrder int) (coeffs [][]float64, errs []float64) {
+	lpc := make([]float64, maxOrder)
+	err := autoc[0]
+	for i := 0; i < maxOrder; i++ {
+		if err <= 0 {
+			break
+		}
+
+		// compute reflection coefficient
+		r := -autoc[i+1]
+		for j := 0; j < i; j++ {
+			r -= lpc[j] * autoc[i-j]
+		}
+		r /= err
+
+		// update LPC coefficients and total error
+		lpc[i] = r
+		for j := 0; j < i>>1; j++ {
+			tmp := lpc[j]
+			lpc[j] += r * lpc[i-1-j]
+			lpc[i-1-j] += r * tmp
+		}
+
+		if i&1 != 0 {
+			lpc[i>>1] += lpc[i>>1] * r
+		}
+		err *= 1 - r*r
+
+		// negate FIR filter coefficients to get predictor coefficients
+		c := make([]float64, i+1)
+		for j := range c {
+			c[j] = -lpc[j]
+		}
+		coeffs = append(coeffs, c)
+		errs = append(errs, err)
+	}
+
+	return coeffs, errs
+}
+
+// Quantize quantizes the given LPC coefficients to signed integers of
+// prec bits, and returns the quantized coefficients and the shift which
+// scales them back; i.e. coeffs[i] ~= qcoeffs[i] / 2^shift.
+// The shift is between 0 and MaxShift,
+// and quantization errors are carried over to subsequent coefficients.
+func Quantize(coeffs []float64, prec uint) (qcoeffs []int32, shift int32, err error) {
+	var cmax float64
+	for _, c := range coeffs {
+		cmax = math.Max(cmax, math.Abs(c))
+	}
+
+	if cmax == 0 {
+		return nil, 0, ErrZeroCoeffs
+	}
+
+	// determine the largest shift for which the largest coefficient
+	// still fits in prec bits, one of which is the sign bit
+	_, exp := math.Frexp(cmax)
+	shift = int32(prec) - 1 - int32(exp)
+	if shift > MaxShift {
+		shift = MaxShift
+	} else if shift < 0 {
+		// the coefficients require more than prec bits
+		shift = 0
+	}
+
+	qmax := int32(1)<<(prec-1) - 1
+	qmin := -qmax - 1
+	qcoeffs = make([]int32, len(coeffs))
+	var e float64
+	scale := float64(int64(1) << uint(shift))
+	for i, c := range coeffs {
+		e += c * scale
+		q := int32(math.Round(e))
+		if q > qmax {
+			q = qmax
+		} else if q < qmin {
+			q = qmin
+		}
+		e -= float64(q)
+		qcoeffs[i] = q
+	}
+
+	return qcoeffs, shift, nil
+}
+
+// ExpectedBits returns the expected number of bits per residual sample,
+// given the prediction error of n samples.
+func ExpectedBits(err float64, n int) float64 {
+	if err <= 0 || n <= 0 {
+		return 0
+	}
+
+	bps := 0.5 * math.Log2(0.5*err/float64(n))
+	return math.Max(bps, 0)
+}
@@ -0,0 +1,145 @@
+package lpc_test
+
+import (
+	"math"
+	"testing"
+
+	"github.com/pchchv/flac/internal/lpc"
+)
+
+func TestCoefficients(t *testing.T) {
+	// second-order autoregressive process: x[n] = 1.6*x[n-1] - 0.8*x[n-2] + e[n]
+	want := []float64{1.6, -0.8}
+	x := make([]float64, 8192)
+	seed := uint32(1)
+	for i := range x {
+		seed = seed*1664525 + 1013904223
+		e := float64(int32(seed)>>16) / 32768
+		if i >= 2 {
+			e += want[0]*x[i-1] + want[1]*x[i-2]
+		}
+		x[i] = e
+	}
+
+	autoc := lpc.Autocorrelation(x, 4)
+	coeffs, errs := lpc.Coefficients(autoc, 4)
+	if len(coeffs) != 4 || len(errs) != 4 {
+		t.Fatalf("order count mismatch; expected 4, got %d coefficient sets and %d errors", len(coeffs), len(errs))
+	}
+
+	for i, c := range coeffs[1] {
+		if math.Abs(c-want[i]) > 0.05 {
+			t.Errorf("coefficient %d mismatch; expected %v, got %v", i, want[i], c)
+		}
+	}
+
+	for i := 1; i < len(errs); i++ {
+		if errs[i] > errs[i-1] {
+			t.Errorf("prediction error of order %d (%v) exceeds error of order %d (%v)", i+1, errs[i], i, errs[i-1])
+		}
+	}
+}
+
+func TestQuantize(t *testing.T) {
+	golden := []struct {
+		coeffs []float64
+		prec   uint
+	}{
+		{coeffs: []float64{1.6, -0.8}, prec: 12},
+		{coeffs: []float64{0.998}, prec: 12},
+		{coeffs: []float64{3.2, -4.1, 2.7, -0.9}, prec: 15},
+		{coeffs: []float64{0.001, 0.0005}, prec: 5},
+	}
+
+	for _, g := range golden {
+		qcoeffs, shift, err := lpc.Quantize(g.coeffs, g.prec)
+		if err != nil {
+			t.Errorf("coeffs=%v: unable to quantize; %v", g.coeffs, err)
+			continue
+		}
+
+		if shift < 0 || shift > lpc.MaxShift {
+			t.Errorf("coeffs=%v: shift %d out of range", g.coeffs, shift)
+		}
+
+		max := int32(1)<<(g.prec-1) - 1
+		for i, q := range qcoeffs {
+			if q > max || q < -max-1 {
+				t.Errorf("coeffs=%v: coefficient %d (%d) exceeds %d-bit precision", g.coeffs, i, q, g.prec)
+			}
+			got := float64(q) / float64(int64(1)<<uint(shift))
+			if shift < lpc.MaxShift && math.Abs(got-g.coeffs[i]) > 2/float64(int64(1)<<uint(shift)) {
+				t.Errorf("coeffs=%v: coefficient %d mismatch; expected %v, got %v", g.coeffs, i, g.coeffs[i], got)
+			}
+		}
+	}
+
+	if _, _, err := lpc.Quantize([]float64{0, 0}, 12); err != lpc.ErrZeroCoeffs {
+		t.Errorf("zero coefficients; expected %v, got %v", lpc.ErrZeroCoeffs, err)
+	}
+}
+
+func TestWindows(t *testing.T) {
+	const n = 1024
+	windows := map[string][]float64{
+		"bartlett":        lpc.Bartlett(n),
+		"bartlett_hann":   lpc.BartlettHann(n),
+		"blackman":        lpc.Blackman(n),
+		"blackman_harris": lpc.BlackmanHarris(n),
+		"connes":          lpc.Connes(n),
+		"flattop":         lpc.Flattop(n),
+		"gauss":           lpc.Gauss(n, 0.25),
+		"hamming":         lpc.Hamming(n),
+		"hann":            lpc.Hann(n),
+		"kaiser_bessel":   lpc.KaiserBessel(n),
+		"nuttall":         lpc.Nuttall(n),
+		"rectangle":       lpc.Rectangle(n),
+		"triangle":        lpc.Triangle(n),
+		"tukey":           lpc.Tukey(n, 0.5),
+		"welch":           lpc.Welch(n),
+	}
+
+	for name, w := range windows {
+		if len(w) != n {
+			t.Errorf("%s: length mismatch; expected %d, got %d", name, n, len(w))
+			continue
+		}
+
+		// windows are symmetric and peak at about 1 in the middle;
+		// the flat top window dips below 0
+		for i := 0; i < n/2; i++ {
+			if math.Abs(w[i]-w[n-1-i]) > 1e-9 {
+				t.Errorf("%s: asymmetric at sample %d; %v != %v", name, i, w[i], w[n-1-i])
+				break
+			}
+			if w[i] < -0.1 || w[i] > 1+1e-9 {
+				t.Errorf("%s: sample %d (%v) out of range", name, i, w[i])
+				break
+			}
+		}
+
+		if mid := w[n/2]; mid < 0.99 {
+			t.Errorf("%s: middle sample %v; expected about 1", name, mid)
+		}
+	}
+
+	// partial Tukey windows are zero outside of their range,
+	// and punchout Tukey windows are zero within it
+	partial := lpc.PartialTukey(n, 0.2, 0.25, 0.5)
+	punchout := lpc.PunchoutTukey(n, 0.2, 0.25, 0.5)
+	for i := 0; i < n; i++ {
+		inside := i >= n/4 && i < n/2
+		if !inside && partial[i] != 0 {
+			t.Errorf("partial_tukey: sample %d outside of range is %v; expected 0", i, partial[i])
+			break
+		}
+		if inside && punchout[i] != 0 {
+			t.Errorf("punchout_tukey: sample %d within range is %v; expected 0", i, punchout[i])
+			break
+		}
+	}
+
+	if partial[3*n/8] != 1 || punchout[n/8] != 1 || punchout[3*n/4] != 1 {
+		t.Errorf("partial and punchout Tukey windows not flat outside of the tapered regions")
+	}
+}
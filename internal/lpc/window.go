@@ -0,0 +1,253 @@
+package lpc
+
+import "math"
+
+// Rectangle returns a rectangular window of n samples.
+func Rectangle(n int) []float64 {
+	w := make([]float64, n)
+	for i := range w {
+		w[i] = 1
+	}
+
+	return w
+}
+
+// Bartlett returns a Bartlett window of n samples.
+func Bartlett(n int) []float64 {
+	w := make([]float64, n)
+	N := float64(n - 1)
+	for i := range w {
+		if i <= (n-1)/2 {
+			w[i] = 2 * float64(i) / N
+		} else {
+			w[i] = 2 - 2*float64(i)/N
+		}
+	}
+
+	return w
+}
+
+// BartlettHann returns a Bartlett-Hann window of n samples.
+func BartlettHann(n int) []float64 {
+	w := make([]float64, n)
+	N := float64(n - 1)
+	for i := range w {
+		x := float64(i) / N
+		w[i] = 0.62 - 0.48*math.Abs(x-0.5) - 0.38*math.Cos(2*math.Pi*x)
+	}
+
+	return w
+}
+
+// Blackman returns a Blackman window of n samples.
+func Blackman(n int) []float64 {
+	return cosineSum(n, 0.42, 0.5, 0.08)
+}
+
+// BlackmanHarris returns a 4-term Blackman-Harris window of n samples,
+// with 92 dB sidelobe attenuation.
+func BlackmanHarris(n int) []float64 {
+	return cosineSum(n, 0.35875, 0.48829, 0.14128, 0.01168)
+}
+
+// Connes returns a Connes window of n samples.
+func Connes(n int) []float64 {
+	w := Welch(n)
+	for i, x := range w {
+		w[i] = x * x
+	}
+
+	return w
+}
+
+// Flattop returns a flat top window of n samples.
+func Flattop(n int) []float64 {
+	return cosineSum(n, 0.21557895, 0.41663158, 0.277263158, 0.083578947, 0.006947368)
+}
+
+// Gauss returns a Gaussian window of n samples,
+// with the standard deviation stddev relative to half the window.
+func Gauss(n int, stddev float64) []float64 {
+	w := make([]float64, n)
+	half := float64(n-1) / 2
+	for i := range w {
+		k := (float64(i) - half) / (stddev * half)
+		w[i] = math.Exp(-0.5 * k * k)
+	}
+
+	return w
+}
+
+// Hamming returns a Hamming window of n samples.
+func Hamming(n int) []float64 {
+	return cosineSum(n, 0.54, 0.46)
+}
+
+// Hann returns a Hann window of n samples.
+func Hann(n int) []float64 {
+	return cosineSum(n, 0.5, 0.5)
+}
+
+// KaiserBessel returns a Kaiser-Bessel window of n samples.
+func KaiserBessel(n int) []float64 {
+	return cosineSum(n, 0.402, 0.498, 0.098, 0.001)
+}
+
+// Nuttall returns a Nuttall window of n samples.
+func Nuttall(n int) []float64 {
+	return cosineSum(n, 0.3635819, 0.4891775, 0.1365995, 0.0106411)
+}
+
+// Triangle returns a triangular window of n samples,
+// which unlike the Bartlett window does not reach zero at its ends.
+func Triangle(n int) []float64 {
+	w := make([]float64, n)
+	N := float64(n + 1)
+	for i := range w {
+		if i < (n+1)/2 {
+			w[i] = 2 * float64(i+1) / N
+		} else {
+			w[i] = 2 * float64(n-i) / N
+		}
+	}
+
+	return w
+}
+
+// Welch returns a Welch window of n samples.
+func Welch(n int) []float64 {
+	w := make([]float64, n)
+	half := float64(n-1) / 2
+	for i := range w {
+		k := (float64(i) - half) / half
+		w[i] = 1 - k*k
+	}
+
+	return w
+}
+
+// Tukey returns a Tukey window of n samples,
+// whose cosine-tapered region spans the fraction p of the window.
+// A p of 0 gives a rectangular window and a p of 1 gives a Hann window.
+func Tukey(n int, p float64) []float64 {
+	w := Rectangle(n)
+	if p <= 0 {
+		return w
+	}
+
+	if p >= 1 {
+		p = 1
+	}
+
+	np := int(p/2*float64(n)) - 1
+	if np > 0 {
+		for i := 0; i <= np; i++ {
+			w[i] = 0.5 - 0.5*math.Cos(math.Pi*float64(i)/float64(np))
+			w[n-np-1+i] = 0.5 - 0.5*math.Cos(math.Pi*float64(i+np)/float64(np))
+		}
+	}
+
+	return w
+}
+
+// PartialTukey returns a window of n samples which is zero outside of
+// the fractional range [start, end) of the window, and a Tukey window
+// with the cosine-tapered fraction p within it.
+// The fraction p is clamped to [0.05, 0.95].
+func PartialTukey(n int, p, start, end float64) []float64 {
+	p = clampTukeyParam(p)
+	w := make([]float64, n)
+	startN, endN := int(start*float64(n)), int(end*float64(n))
+	np := int(p / 2 * float64(endN-startN))
+	i := startN
+	for j := 1; i < startN+np && i < n; i, j = i+1, j+1 {
+		w[i] = raisedCosine(j, np)
+	}
+
+	for ; i < endN-np && i < n; i++ {
+		w[i] = 1
+	}
+
+	for j := np; i < endN && i < n; i, j = i+1, j-1 {
+		w[i] = raisedCosine(j, np)
+	}
+
+	return w
+}
+
+// PunchoutTukey returns a window of n samples which is zero within
+// the fractional range [start, end) of the window, and a Tukey window
+// with the cosine-tapered fraction p on either side of it.
+// The fraction p is clamped to [0.05, 0.95].
+func PunchoutTukey(n int, p, start, end float64) []float64 {
+	p = clampTukeyParam(p)
+	w := make([]float64, n)
+	startN, endN := int(start*float64(n)), int(end*float64(n))
+	ns := int(p / 2 * float64(startN))
+	ne := int(p / 2 * float64(n-endN))
+	i := 0
+	for j := 1; i < ns && i < n; i, j = i+1, j+1 {
+		w[i] = raisedCosine(j, ns)
+	}
+
+	for ; i < startN-ns && i < n; i++ {
+		w[i] = 1
+	}
+
+	for j := ns; i < startN && i < n; i, j = i+1, j-1 {
+		w[i] = raisedCosine(j, ns)
+	}
+
+	// the punched out range is left as zero
+	if i < endN {
+		i = endN
+	}
+
+	for j := 1; i < endN+ne && i < n; i, j = i+1, j+1 {
+		w[i] = raisedCosine(j, ne)
+	}
+
+	for ; i < n-ne; i++ {
+		w[i] = 1
+	}
+
+	for j := ne; i < n; i, j = i+1, j-1 {
+		w[i] = raisedCosine(j, ne)
+	}
+
+	return w
+}
+
+// cosineSum returns a generalized cosine window of n samples
+// with the coefficients a, of alternating sign.
+func cosineSum(n int, a ...float64) []float64 {
+	w := make([]float64, n)
+	N := float64(n - 1)
+	for i := range w {
+		sign := 1.0
+		for k, ak := range a {
+			w[i] += sign * ak * math.Cos(2*math.Pi*float64(k)*float64(i)/N)
+			sign = -sign
+		}
+	}
+
+	return w
+}
+
+// raisedCosine returns sample i of a cosine taper of np samples.
+func raisedCosine(i, np int) float64 {
+	return 0.5 - 0.5*math.Cos(math.Pi*float64(i)/float64(np))
+}
+
+// clampTukeyParam clamps the cosine-tapered fraction of
+// partial Tukey windows to [0.05, 0.95].
+func clampTukeyParam(p float64) float64 {
+	switch {
+	case p <= 0:
+		return 0.05
+	case p >= 1:
+		return 0.95
+	}
+
+	return p
+}
@@ -4,8 +4,6 @@ import (
 	"errors"
 	"fmt"
 	"io"
-
-	"github.com/pchchv/flac/internal/ioutilx"
 )
 
 // Decode decodes a "UTF-8" coded number and returns it.
@@ -28,8 +26,8 @@ import (
 //   - if B does not match 10xxxxxx, the encoding is invalid
 //   - set R = R or <the lower 6 bits from B>
 //   - the read value is R
-func Decode(r io.Reader) (x uint64, err error) {
-	c0, err := ioutilx.ReadByte(r)
+func Decode(r io.ByteReader) (x uint64, err error) {
+	c0, err := r.ReadByte()
 	if err != nil {
 		return 0, err
 	}
@@ -85,7 +83,7 @@ func Decode(r io.Reader) (x uint64, err error) {
 	// store bits from continuation bytes
 	for i := 0; i < l; i++ {
 		x <<= 6
-		c, err := ioutilx.ReadByte(r)
+		c, err := r.ReadByte()
 		if err != nil {
 			if err == io.EOF {
 				return 0, io.ErrUnexpectedEOF
@@ -0,0 +1,205 @@
+package flac
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"io"
+
+	"github.com/pchchv/flac/frame"
+	"github.com/pchchv/flac/internal/bufseekio"
+)
+
+// SyncLoss describes corrupt data of a stream, which was skipped by
+// ParseNext in resync mode to regain sync with the frames of the stream.
+type SyncLoss struct {
+	// Error of the frame which caused the loss of sync.
+	Err error
+	// Number of bytes skipped.
+	Bytes int64
+	// Number of samples (per channel) skipped, derived from
+	// the sample numbers of the frames around the skipped bytes.
+	Samples uint64
+}
+
+// errLostSync is returned by peekFrame for frames which do not confirm sync.
+var errLostSync = errors.New("flac.Stream.ParseNext: frame does not confirm sync")
+
+// peeker is implemented by the buffered readers of streams.
+type peeker interface {
+	Peek(n int) ([]byte, error)
+	Discard(n int) (int, error)
+}
+
+// EnableResync enables lost-sync recovery of ParseNext.
+// If a frame is corrupt, the stream is scanned forward byte by byte for the
+// sync code of a frame header with a valid CRC-8 checksum and a sample number
+// following the last frame decoded. The frame must decode without errors,
+// and be followed by the header of the next frame or the end of the stream.
+// Decoding resumes from that frame, and fn (if not nil) is called with
+// the number of bytes and samples skipped.
+func (stream *Stream) EnableResync(fn func(SyncLoss)) {
+	// frames are decoded from the buffer of the stream,
+	// which must hold a frame and the header of the following frame
+	size := stream.maxFrameSize() + maxFrameHeaderSize
+	switch r := stream.r.(type) {
+	case *bufio.Reader:
+		stream.r = bufio.NewReaderSize(r, size)
+	case *bufseekio.ReadSeeker:
+		stream.r = bufseekio.NewReadSeekerSize(r, size)
+	}
+
+	stream.resync = true
+	stream.onSyncLoss = fn
+}
+
+// parseNextResync parses the entire next frame including audio samples into f,
+// recovering from corrupt frames as described by EnableResync.
+func (stream *Stream) parseNextResync(f *frame.Frame) (*frame.Frame, error) {
+	p := stream.r.(peeker)
+	n, err := stream.peekFrame(p, f, false)
+	if err == nil {
+		return stream.discardFrame(p, f, n)
+	}
+
+	if err == io.EOF {
+		return nil, io.EOF
+	}
+
+	loss := SyncLoss{Err: err}
+	for {
+		// skip to the next sync code
+		if _, err := p.Discard(1); err != nil {
+			return nil, stream.lostSync(loss, err)
+		}
+		loss.Bytes++
+
+		skipped, err := syncPeeker(p)
+		loss.Bytes += int64(skipped)
+		if err != nil {
+			return nil, stream.lostSync(loss, err)
+		}
+
+		n, err := stream.peekFrame(p, f, true)
+		if err != nil {
+			continue
+		}
+
+		loss.Samples = stream.sampleNumber(f) - stream.nextSample
+		stream.lostSync(loss, nil)
+		return stream.discardFrame(p, f, n)
+	}
+}
+
+// lostSync reports the given loss of sync, and returns the given error;
+// io.EOF if the end of the stream was reached while regaining sync.
+func (stream *Stream) lostSync(loss SyncLoss, err error) error {
+	if err == io.ErrUnexpectedEOF {
+		err = io.EOF
+	}
+
+	if stream.onSyncLoss != nil {
+		stream.onSyncLoss(loss)
+	}
+
+	return err
+}
+
+// discardFrame consumes the n bytes of the given frame from p.
+func (stream *Stream) discardFrame(p peeker, f *frame.Frame, n int) (*frame.Frame, error) {
+	if _, err := p.Discard(n); err != nil {
+		return nil, err
+	}
+
+	stream.setNextSample(f)
+	return f, nil
+}
+
+// peekFrame parses the entire frame at the start of p into f without consuming it,
+// and returns the size of the frame in bytes. If sync is to be confirmed,
+// the frame must be consistent with StreamInfo, follow the last frame decoded,
+// and be followed by the header of the next frame or the end of the stream.
+func (stream *Stream) peekFrame(p peeker, f *frame.Frame, confirm bool) (int, error) {
+	buf, err := p.Peek(stream.maxFrameSize() + maxFrameHeaderSize)
+	if len(buf) == 0 {
+		return 0, err
+	}
+
+	if stream.peekReader == nil {
+		stream.peekReader = new(bytes.Reader)
+	}
+	r := stream.peekReader
+	r.Reset(buf)
+	if err := frame.ParseInto(r, f); err != nil {
+		return 0, err
+	}
+
+	n := len(buf) - r.Len()
+	if !confirm {
+		return n, nil
+	}
+
+	if !stream.isFrameHeader(f) || stream.sampleNumber(f) < stream.nextSample {
+		return 0, errLostSync
+	}
+
+	// the end of the stream or the header of the next frame follows
+	if r.Len() == 0 {
+		return n, nil
+	}
+
+	next, err := frame.New(r)
+	if err != nil || !stream.isFrameHeader(next) || stream.sampleNumber(next) != stream.sampleNumber(f)+uint64(f.BlockSize) {
+		return 0, errLostSync
+	}
+
+	return n, nil
+}
+
+// syncPeeker skips to the next frame sync code of p, and returns the number of bytes skipped.
+func syncPeeker(p peeker) (int, error) {
+	var skipped int
+	for {
+		buf, err := p.Peek(syncBufSize)
+		if len(buf) < 2 {
+			if err == nil {
+				err = io.EOF
+			}
+			return skipped, err
+		}
+
+		i := bytes.IndexByte(buf[:len(buf)-1], 0xFF)
+		if i == -1 {
+			i = len(buf) - 1
+		} else if buf[i+1]&0xFE == 0xF8 {
+			_, err := p.Discard(i)
+			return skipped + i, err
+		} else {
+			i++
+		}
+
+		if _, err := p.Discard(i); err != nil {
+			return skipped, err
+		}
+		skipped += i
+	}
+}
+
+// maxFrameSize returns an upper bound of the size in bytes of the frames of the stream,
+// based on verbatim coding of the audio samples of every channel.
+func (stream *Stream) maxFrameSize() int {
+	info := stream.Info
+	blockSize := int(info.BlockSizeMax)
+	if blockSize == 0 {
+		blockSize = 65535
+	}
+
+	// subframe header, wasted bits and an extra bit per sample of a side channel
+	bps := int(info.BitsPerSample) + 1
+	n := maxFrameHeaderSize + int(info.NChannels)*(2+bps/8+(blockSize*bps+7)/8) + 2
+	if m := int(info.FrameSizeMax); m > n {
+		return m
+	}
+
+	return n
+}
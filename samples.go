@@ -0,0 +1,105 @@
+package flac
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/pchchv/flac/frame"
+)
+
+// ReadSamples reads decoded audio samples of the stream into buf, interleaved
+// such that the samples of all channels are stored consecutively for each
+// sample position. Samples are read across frame boundaries; the position
+// within the current frame is kept between calls, so buf may be of any size.
+//
+// It returns the number of samples read. At the end of the stream,
+// ReadSamples returns 0 and io.EOF. ReadSamples and ReadSamplesPlanar
+// should not be mixed with calls to Next and ParseNext.
+func (stream *Stream) ReadSamples(buf []int32) (n int, err error) {
+	for n < len(buf) {
+		f, err := stream.currentFrame()
+		if err != nil {
+			if err == io.EOF && n > 0 {
+				return n, nil
+			}
+			return n, err
+		}
+
+		nchannels := len(f.Subframes)
+		end := int(f.BlockSize) * nchannels
+		pos := stream.framePos
+		for ; n < len(buf) && pos < end; pos++ {
+			buf[n] = f.Subframes[pos%nchannels].Samples[pos/nchannels]
+			n++
+		}
+		stream.framePos = pos
+	}
+
+	return n, nil
+}
+
+// ReadSamplesPlanar reads decoded audio samples of the stream into buf,
+// stored separately for each channel. Every channel of buf must hold the
+// same number of samples. Samples are read across frame boundaries, as
+// described by ReadSamples.
+//
+// It returns the number of samples per channel read. At the end of the stream,
+// ReadSamplesPlanar returns 0 and io.EOF. The position within the current frame
+// must not be between the channels of a sample position, as left by ReadSamples.
+func (stream *Stream) ReadSamplesPlanar(buf [][]int32) (n int, err error) {
+	nchannels := int(stream.Info.NChannels)
+	if len(buf) != nchannels {
+		return 0, fmt.Errorf("flac.Stream.ReadSamplesPlanar: channel count mismatch; expected %d, got %d", nchannels, len(buf))
+	}
+
+	for i, channel := range buf {
+		if len(channel) != len(buf[0]) {
+			return 0, fmt.Errorf("flac.Stream.ReadSamplesPlanar: invalid number of samples in channel %d; expected %d, got %d", i, len(buf[0]), len(channel))
+		}
+	}
+
+	for n < len(buf[0]) {
+		f, err := stream.currentFrame()
+		if err != nil {
+			if err == io.EOF && n > 0 {
+				return n, nil
+			}
+			return n, err
+		}
+
+		if len(f.Subframes) != nchannels {
+			return n, fmt.Errorf("flac.Stream.ReadSamplesPlanar: subframe and channel count mismatch; expected %d, got %d", nchannels, len(f.Subframes))
+		}
+
+		if stream.framePos%nchannels != 0 {
+			return n, fmt.Errorf("flac.Stream.ReadSamplesPlanar: position within sample of %d interleaved channels", nchannels)
+		}
+
+		start := stream.framePos / nchannels
+		m := copy(buf[0][n:], f.Subframes[0].Samples[start:f.BlockSize])
+		for channel := 1; channel < nchannels; channel++ {
+			copy(buf[channel][n:n+m], f.Subframes[channel].Samples[start:])
+		}
+		stream.framePos += m * nchannels
+		n += m
+	}
+
+	return n, nil
+}
+
+// currentFrame returns the audio frame being read by ReadSamples and
+// ReadSamplesPlanar, parsing the next frame once all samples have been read.
+func (stream *Stream) currentFrame() (*frame.Frame, error) {
+	f := stream.frame
+	if f != nil && stream.framePos < int(f.BlockSize)*len(f.Subframes) {
+		return f, nil
+	}
+
+	f, err := stream.ParseNext()
+	if err != nil {
+		return nil, err
+	}
+
+	stream.frame, stream.framePos = f, 0
+	return f, nil
+}
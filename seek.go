@@ -0,0 +1,286 @@
+package flac
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+
+	"github.com/pchchv/flac/frame"
+)
+
+// syncBufSize is the size of the buffer used to scan for frame sync codes.
+const syncBufSize = 4096
+
+// bisect seeks to the frame containing the given absolute sample number
+// by bisection on the byte offset of frames, without a seek table.
+// Only the frame headers of candidate frames are parsed, except for the frame
+// containing sampleNum, which is decoded to confirm it. Candidate frames which
+// fail to decode are rejected, and the bisection is repeated without them.
+// It returns the first sample number of the frame containing sampleNum.
+func (stream *Stream) bisect(rs io.ReadSeeker, sampleNum uint64) (uint64, error) {
+	end, err := rs.Seek(0, io.SeekEnd)
+	if err != nil {
+		return 0, err
+	}
+
+	// byte offsets of sync codes rejected as frame headers
+	var rejected map[int64]bool
+	for {
+		offsets, f, err := stream.bisectHeader(rs, sampleNum, end, rejected)
+		if err != nil {
+			return 0, err
+		}
+
+		// if the frame containing sampleNum was not found, the bisection was
+		// misled by a false frame header; every candidate frame is decoded
+		if f != nil {
+			offsets = offsets[len(offsets)-1:]
+		}
+
+		var nrejected int
+		for _, off := range offsets {
+			ok, err := stream.isFrameAt(rs, off)
+			if err != nil {
+				return 0, err
+			}
+
+			if !ok {
+				if rejected == nil {
+					rejected = make(map[int64]bool)
+				}
+				rejected[off] = true
+				nrejected++
+			}
+		}
+
+		switch {
+		case f != nil && nrejected == 0:
+			_, err = rs.Seek(offsets[0], io.SeekStart)
+			return stream.sampleNumber(f), err
+		case nrejected == 0:
+			return 0, fmt.Errorf("unable to seek to sample number %d", sampleNum)
+		}
+	}
+}
+
+// bisectHeader locates the header of the frame containing the given absolute
+// sample number by bisection on the byte offset of frames up to end.
+// The sample numbers of candidate frames must follow the frames preceding them,
+// and precede the frames following them. It returns the byte offsets of the
+// candidate frames bisected, and the header of the frame containing sampleNum,
+// whose byte offset is the last one; or a nil header if not found.
+func (stream *Stream) bisectHeader(rs io.ReadSeeker, sampleNum uint64, end int64, rejected map[int64]bool) ([]int64, *frame.Frame, error) {
+	// the first frame starting at or after lo starts at or before sampleNum,
+	// and every frame starting at or after hi starts after sampleNum, at or
+	// before the sample number last
+	last := uint64(math.MaxUint64)
+	lo, f, err := stream.nextFrameHeader(rs, stream.dataStart, 0, last, rejected)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	offsets := []int64{lo}
+	hi := end
+	for !stream.hasSample(f, sampleNum) {
+		mid := lo + (hi-lo)/2
+		if mid <= lo {
+			return offsets, nil, nil
+		}
+
+		first := stream.sampleNumber(f) + uint64(f.BlockSize)
+		off, g, err := stream.nextFrameHeader(rs, mid, first, last, rejected)
+		switch {
+		case err == io.EOF || err == nil && off >= hi:
+			hi = mid
+			continue
+		case err != nil:
+			return nil, nil, err
+		case stream.sampleNumber(g) > sampleNum:
+			hi, last = mid, stream.sampleNumber(g)
+		default:
+			lo, f = off, g
+		}
+		offsets = append(offsets, off)
+	}
+
+	return offsets, f, nil
+}
+
+// isFrameAt reports whether the frame starting at the given byte offset
+// decodes with valid CRC-8 and CRC-16 checksums.
+func (stream *Stream) isFrameAt(rs io.ReadSeeker, offset int64) (bool, error) {
+	if _, err := rs.Seek(offset, io.SeekStart); err != nil {
+		return false, err
+	}
+
+	if stream.seekFrame == nil {
+		stream.seekFrame = new(frame.Frame)
+	}
+
+	return frame.ParseInto(stream.seekReader(rs), stream.seekFrame) == nil, nil
+}
+
+// hasSample reports whether the given frame contains the absolute sample number.
+func (stream *Stream) hasSample(f *frame.Frame, sampleNum uint64) bool {
+	first := stream.sampleNumber(f)
+	return first <= sampleNum && sampleNum < first+uint64(f.BlockSize)
+}
+
+// nextFrameHeader returns the byte offset and the parsed header of the first frame
+// starting at or after the given byte offset, whose first sample number is within
+// [first, last). Candidate frames are located by their sync code, and must have
+// a frame header consistent with StreamInfo with a valid CRC-8 checksum, at a
+// byte offset which has not been rejected. It returns io.EOF if no frame is found.
+func (stream *Stream) nextFrameHeader(rs io.ReadSeeker, offset int64, first, last uint64, rejected map[int64]bool) (int64, *frame.Frame, error) {
+	if _, err := rs.Seek(offset, io.SeekStart); err != nil {
+		return 0, nil, err
+	}
+
+	br := stream.seekReader(rs)
+	for {
+		skipped, err := syncPeeker(br)
+		if err != nil {
+			return 0, nil, err
+		}
+		offset += int64(skipped)
+
+		if !rejected[offset] {
+			f, err := stream.peekFrameHeader(br)
+			if err == nil && first <= stream.sampleNumber(f) && stream.sampleNumber(f) < last {
+				return offset, f, nil
+			}
+		}
+
+		if _, err := br.Discard(1); err != nil {
+			return 0, nil, err
+		}
+		offset++
+	}
+}
+
+// seekReader returns the buffered reader used to scan rs for frames while
+// seeking, reset to read from the current position of rs.
+func (stream *Stream) seekReader(rs io.Reader) *bufio.Reader {
+	if stream.seekBuf == nil {
+		stream.seekBuf = bufio.NewReaderSize(rs, syncBufSize)
+	} else {
+		stream.seekBuf.Reset(rs)
+	}
+
+	return stream.seekBuf
+}
+
+// isFrameHeader reports whether the given frame header is consistent with
+// StreamInfo; to reject sync codes found within the audio data of frames.
+func (stream *Stream) isFrameHeader(f *frame.Frame) bool {
+	info := stream.Info
+	switch {
+	case f.Channels.Count() != int(info.NChannels):
+		return false
+	case f.BitsPerSample != 0 && f.BitsPerSample != info.BitsPerSample:
+		return false
+	case f.SampleRate != 0 && f.SampleRate != info.SampleRate:
+		return false
+	case info.BlockSizeMax != 0 && f.BlockSize > info.BlockSizeMax:
+		return false
+	case info.NSamples != 0 && stream.sampleNumber(f) >= info.NSamples:
+		return false
+	}
+
+	return true
+}
+
+// maxFrameHeaderSize is the largest size in bytes of a frame header, including
+// a 7-byte UTF-8 coded number, 16-bit block size and sample rate, and the CRC-8.
+const maxFrameHeaderSize = 16
+
+// skip seeks forward to the frame containing the given absolute sample number
+// in a stream which is not seekable, starting at the next frame of br.
+// Frames are skipped by locating the header of the following frame,
+// without decoding their subframes. It returns the first sample number
+// of the frame containing sampleNum.
+func (stream *Stream) skip(br *bufio.Reader, sampleNum uint64) (uint64, error) {
+	f, err := stream.peekFrameHeader(br)
+	if err != nil {
+		if err == io.EOF {
+			return 0, fmt.Errorf("unable to seek to sample number %d", sampleNum)
+		}
+		return 0, err
+	}
+
+	if stream.sampleNumber(f) > sampleNum {
+		return 0, ErrNoSeeker
+	}
+
+	// discard the audio frame being read by ReadSamples
+	stream.frame, stream.framePos = nil, 0
+	for !stream.hasSample(f, sampleNum) {
+		next := stream.sampleNumber(f) + uint64(f.BlockSize)
+		if f, err = stream.skipFrame(br, next); err != nil {
+			if err == io.EOF {
+				return 0, fmt.Errorf("unable to seek to sample number %d", sampleNum)
+			}
+			return 0, err
+		}
+	}
+
+	return stream.sampleNumber(f), nil
+}
+
+// skipFrame skips the frame at the start of br, up to the header of the
+// following frame, which must start at the given sample number.
+// It returns the header of the following frame, which is not consumed from br.
+func (stream *Stream) skipFrame(br *bufio.Reader, next uint64) (*frame.Frame, error) {
+	// skip the sync code of the current frame
+	if _, err := br.Discard(2); err != nil {
+		return nil, err
+	}
+
+	for {
+		buf, err := br.Peek(br.Buffered())
+		if len(buf) < 2 {
+			// refill the buffer; at least 2 bytes are needed for a sync code
+			if buf, err = br.Peek(2); err != nil {
+				return nil, err
+			}
+		}
+
+		i := bytes.IndexByte(buf[:len(buf)-1], 0xFF)
+		if i == -1 {
+			br.Discard(len(buf) - 1)
+			continue
+		}
+		br.Discard(i)
+
+		if buf[i+1]&0xFE == 0xF8 {
+			f, err := stream.peekFrameHeader(br)
+			if err == nil && stream.sampleNumber(f) == next {
+				return f, nil
+			}
+		}
+		br.Discard(1)
+	}
+}
+
+// peekFrameHeader parses the frame header at the start of p, without consuming it.
+// The frame header must be consistent with StreamInfo.
+func (stream *Stream) peekFrameHeader(p peeker) (*frame.Frame, error) {
+	buf, err := p.Peek(maxFrameHeaderSize)
+	if len(buf) == 0 {
+		return nil, err
+	}
+
+	f, err := frame.New(bytes.NewReader(buf))
+	if err != nil {
+		return nil, err
+	}
+
+	if !stream.isFrameHeader(f) {
+		return nil, errors.New("flac.Stream.Seek: frame header inconsistent with StreamInfo")
+	}
+
+	return f, nil
+}
@@ -0,0 +1,54 @@
+package flac
+
+import (
+	"bytes"
+	"crypto/md5"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/pchchv/flac/frame"
+)
+
+// ErrMD5Mismatch is wrapped by the error returned by ParseNext at the end of
+// the stream in place of io.EOF, when the MD5 checksum of the decoded audio
+// samples differs from the MD5 checksum of StreamInfo.
+var ErrMD5Mismatch = errors.New("flac.Stream.ParseNext: MD5 checksum mismatch")
+
+// EnableMD5Check enables verification of the decoded audio samples against the
+// MD5 checksum of StreamInfo. ParseNext adds the audio samples of every frame to
+// a running MD5 hash, and at the end of the stream returns an error wrapping
+// ErrMD5Mismatch instead of io.EOF if the checksums differ.
+//
+// EnableMD5Check must be called before the first frame is parsed. Streams with
+// an all-zero MD5 checksum, meaning unset, are not verified. Seeking disables
+// the verification, as the audio samples preceding the sought frame are not hashed.
+func (stream *Stream) EnableMD5Check() {
+	if stream.Info.MD5sum == [md5.Size]uint8{} {
+		return
+	}
+
+	stream.md5sum = md5.New()
+}
+
+// verifyMD5 adds the audio samples of the given frame to the running MD5 hash,
+// and verifies the MD5 checksum at the end of the stream.
+func (stream *Stream) verifyMD5(f *frame.Frame, err error) (*frame.Frame, error) {
+	if err == nil {
+		f.Hash(stream.md5sum)
+		return f, nil
+	}
+
+	if err != io.EOF {
+		return f, err
+	}
+
+	// verify once; io.EOF is returned by subsequent calls
+	got := stream.md5sum.Sum(nil)
+	stream.md5sum = nil
+	if want := stream.Info.MD5sum[:]; !bytes.Equal(got, want) {
+		return nil, fmt.Errorf("%w; expected %032x, got %032x", ErrMD5Mismatch, want, got)
+	}
+
+	return nil, io.EOF
+}